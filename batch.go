@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultBatchQPS of 0 means unlimited: batch resolution isn't rate limited
+// unless --qps is given.
+const defaultBatchQPS = 0
+
+// tokenBucket is a simple QPS rate limiter: take() blocks the caller until
+// the next token, spaced 1/qps apart, is due.
+type tokenBucket struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+// newTokenBucket returns nil (no limiting) when qps is zero or negative.
+func newTokenBucket(qps float64) *tokenBucket {
+	if qps <= 0 {
+		return nil
+	}
+	return &tokenBucket{interval: time.Duration(float64(time.Second) / qps), next: time.Now()}
+}
+
+func (b *tokenBucket) take() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	wait := b.next.Sub(now)
+	b.next = b.next.Add(b.interval)
+	b.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// singleflightCall tracks one in-flight resolveDNS call so concurrent
+// lookups for the same domain can share its result.
+type singleflightCall struct {
+	wg      sync.WaitGroup
+	records map[string][]string
+	errs    []string
+}
+
+// singleflightGroup deduplicates concurrent batch lookups for the same
+// normalized domain into a single query and a single cache insert.
+type singleflightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*singleflightCall
+}
+
+func newSingleflightGroup() *singleflightGroup {
+	return &singleflightGroup{calls: make(map[string]*singleflightCall)}
+}
+
+func (g *singleflightGroup) do(key string, fn func() (map[string][]string, []string)) (map[string][]string, []string) {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.records, call.errs
+	}
+	call := &singleflightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.records, call.errs = fn()
+	call.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return call.records, call.errs
+}
+
+// batchStats accumulates per-lookup latency and outcome for the summary
+// printed once a batch run finishes.
+type batchStats struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	successes int
+	failures  int
+}
+
+func (s *batchStats) record(d time.Duration, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies = append(s.latencies, d)
+	if ok {
+		s.successes++
+	} else {
+		s.failures++
+	}
+}
+
+// percentile returns the p-th percentile (0-1) of an already-sorted slice.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+func (s *batchStats) summary() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sorted := append([]time.Duration(nil), s.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return fmt.Sprintf("\nBatch summary: %d succeeded, %d failed, p50=%s, p95=%s\n",
+		s.successes, s.failures, percentile(sorted, 0.50), percentile(sorted, 0.95))
+}
+
+// resolveBatch reads domains from a file and resolves them concurrently,
+// bounded to concurrency workers (default runtime.NumCPU()*4) and, if qps is
+// positive, rate limited to qps queries per second. Concurrent lookups for
+// the same normalized domain are deduplicated via singleflight so only one
+// query and one cache insert happens per domain. A summary of success/error
+// counts and p50/p95 latency is printed once every domain has resolved.
+func resolveBatch(filePath string, transport Transport, format OutputFormat, concurrency int, qps float64) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Fatalf("Error reading file: %v\n", err)
+	}
+	defer file.Close()
+
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU() * 4
+	}
+
+	sem := make(chan struct{}, concurrency)
+	limiter := newTokenBucket(qps)
+	group := newSingleflightGroup()
+	stats := &batchStats{}
+
+	scanner := bufio.NewScanner(file)
+	var wg sync.WaitGroup
+	for scanner.Scan() {
+		domain := scanner.Text()
+		if domain == "" {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(domain string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			limiter.take()
+			if format == FormatText {
+				fmt.Printf("\nResolving: %s\n", domain)
+			}
+
+			start := time.Now()
+			records, errs := group.do(normalizeDomain(domain), func() (map[string][]string, []string) {
+				records, errs, _ := resolveDNS(domain, transport, false)
+				return records, errs
+			})
+			stats.record(time.Since(start), len(errs) == 0)
+
+			printFormatted(domain, records, errs, nil, format, transport)
+		}(domain)
+	}
+	wg.Wait()
+
+	fmt.Print(stats.summary())
+}
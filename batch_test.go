@@ -0,0 +1,136 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewTokenBucketDisabledWhenQPSNotPositive(t *testing.T) {
+	if b := newTokenBucket(0); b != nil {
+		t.Errorf("newTokenBucket(0) = %v, want nil", b)
+	}
+	if b := newTokenBucket(-1); b != nil {
+		t.Errorf("newTokenBucket(-1) = %v, want nil", b)
+	}
+}
+
+func TestTokenBucketNilTakeIsNoop(t *testing.T) {
+	var b *tokenBucket
+	done := make(chan struct{})
+	go func() {
+		b.take()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("take() on a nil tokenBucket blocked, want immediate return")
+	}
+}
+
+func TestTokenBucketPacesCalls(t *testing.T) {
+	const qps = 20.0 // 50ms between tokens
+	b := newTokenBucket(qps)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		b.take()
+	}
+	elapsed := time.Since(start)
+
+	// The first take() is immediate; the next two are spaced one interval
+	// apart, so 3 tokens should take roughly 2 intervals (100ms), not 0.
+	want := 2 * time.Duration(float64(time.Second)/qps)
+	if elapsed < want {
+		t.Errorf("3 take() calls at %v qps took %v, want at least %v", qps, elapsed, want)
+	}
+}
+
+func TestSingleflightGroupDeduplicatesConcurrentCalls(t *testing.T) {
+	g := newSingleflightGroup()
+
+	var calls int32
+	var mu sync.Mutex
+	fn := func() (map[string][]string, []string) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		return map[string][]string{"A": {"1.1.1.1"}}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]map[string][]string, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			records, _ := g.do("example.com", fn)
+			results[i] = records
+		}(i)
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("fn was called %d times for 5 concurrent do() calls with the same key, want 1", calls)
+	}
+	for i, records := range results {
+		if records["A"][0] != "1.1.1.1" {
+			t.Errorf("result %d = %v, want the shared fn result", i, records)
+		}
+	}
+}
+
+func TestSingleflightGroupDoesNotDeduplicateDifferentKeys(t *testing.T) {
+	g := newSingleflightGroup()
+	var calls int32
+	var mu sync.Mutex
+	fn := func() (map[string][]string, []string) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return nil, nil
+	}
+
+	g.do("a.com", fn)
+	g.do("b.com", fn)
+
+	if calls != 2 {
+		t.Errorf("fn was called %d times for 2 distinct keys, want 2", calls)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		30 * time.Millisecond,
+		40 * time.Millisecond,
+		50 * time.Millisecond,
+	}
+	if got := percentile(sorted, 0); got != 10*time.Millisecond {
+		t.Errorf("percentile(0) = %v, want 10ms", got)
+	}
+	if got := percentile(sorted, 1); got != 50*time.Millisecond {
+		t.Errorf("percentile(1) = %v, want 50ms", got)
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("percentile on an empty slice = %v, want 0", got)
+	}
+}
+
+func TestBatchStatsRecordAndSummary(t *testing.T) {
+	s := &batchStats{}
+	s.record(10*time.Millisecond, true)
+	s.record(20*time.Millisecond, false)
+
+	if s.successes != 1 || s.failures != 1 {
+		t.Errorf("successes=%d failures=%d, want 1 and 1", s.successes, s.failures)
+	}
+
+	summary := s.summary()
+	if summary == "" {
+		t.Error("summary() returned an empty string")
+	}
+}
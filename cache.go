@@ -0,0 +1,104 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// defaultCacheTTL is used when a cached result has no TTL of its own, e.g.
+// anything resolved through the stdlib net.Lookup* path.
+const defaultCacheTTL = 10 * time.Minute
+
+// defaultCacheCapacity is the LRU size used unless --cache-size overrides it.
+const defaultCacheCapacity = 512
+
+type cacheEntry struct {
+	key     string
+	records map[string][]string
+	expiry  time.Time
+}
+
+// lruCache is a size-bounded cache of resolved DNS records. Entries expire
+// after their TTL and the least recently used entry is evicted once the
+// cache is full, so long-running batch runs don't grow without bound.
+type lruCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+func newLRUCache(capacity int) *lruCache {
+	if capacity <= 0 {
+		capacity = defaultCacheCapacity
+	}
+	return &lruCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache) get(key string) (map[string][]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expiry) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.records, true
+}
+
+// set inserts or refreshes records for key, expiring after ttl (or
+// defaultCacheTTL if ttl is zero).
+func (c *lruCache) set(key string, records map[string][]string, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.records = records
+		entry.expiry = time.Now().Add(ttl)
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	el := c.ll.PushFront(&cacheEntry{key: key, records: records, expiry: time.Now().Add(ttl)})
+	c.items[key] = el
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		if oldest != nil {
+			c.ll.Remove(oldest)
+			delete(c.items, oldest.Value.(*cacheEntry).key)
+		}
+	}
+}
+
+// defaultCache backs cacheResult/getCachedResult. main() may replace it with
+// a differently sized instance before any lookups happen, to honor
+// --cache-size.
+var defaultCache = newLRUCache(defaultCacheCapacity)
+
+// cacheResult caches DNS records for a domain for ttl (or the default 10
+// minutes if ttl is unknown, e.g. results from the stdlib lookup path).
+func cacheResult(domain string, records map[string][]string, ttl time.Duration) {
+	defaultCache.set(domain, records, ttl)
+}
+
+// getCachedResult retrieves cached DNS records, if present and unexpired.
+func getCachedResult(domain string) (map[string][]string, bool) {
+	return defaultCache.get(domain)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUCacheGetSet(t *testing.T) {
+	c := newLRUCache(2)
+
+	if _, ok := c.get("example.com"); ok {
+		t.Fatal("get on an empty cache returned ok = true")
+	}
+
+	records := map[string][]string{"A": {"93.184.216.34"}}
+	c.set("example.com", records, time.Minute)
+
+	got, ok := c.get("example.com")
+	if !ok {
+		t.Fatal("get after set returned ok = false")
+	}
+	if got["A"][0] != "93.184.216.34" {
+		t.Errorf("get returned %v, want %v", got, records)
+	}
+}
+
+func TestLRUCacheExpiry(t *testing.T) {
+	c := newLRUCache(2)
+	// set treats ttl <= 0 as "use the default", so a short positive ttl plus
+	// a sleep is used to exercise expiry instead.
+	c.set("example.com", map[string][]string{"A": {"1.1.1.1"}}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.get("example.com"); ok {
+		t.Error("get returned ok = true for an entry whose ttl already elapsed")
+	}
+}
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLRUCache(2)
+	c.set("a.com", map[string][]string{"A": {"1.1.1.1"}}, time.Minute)
+	c.set("b.com", map[string][]string{"A": {"2.2.2.2"}}, time.Minute)
+
+	// Touch a.com so b.com becomes the least recently used entry.
+	if _, ok := c.get("a.com"); !ok {
+		t.Fatal("get(a.com) returned ok = false before eviction")
+	}
+
+	c.set("c.com", map[string][]string{"A": {"3.3.3.3"}}, time.Minute)
+
+	if _, ok := c.get("b.com"); ok {
+		t.Error("get(b.com) returned ok = true, want it evicted as least recently used")
+	}
+	if _, ok := c.get("a.com"); !ok {
+		t.Error("get(a.com) returned ok = false, want it retained as recently used")
+	}
+	if _, ok := c.get("c.com"); !ok {
+		t.Error("get(c.com) returned ok = false, want it retained as just inserted")
+	}
+}
+
+func TestLRUCacheSetRefreshesExistingKey(t *testing.T) {
+	c := newLRUCache(2)
+	c.set("example.com", map[string][]string{"A": {"1.1.1.1"}}, time.Minute)
+	c.set("example.com", map[string][]string{"A": {"2.2.2.2"}}, time.Minute)
+
+	got, ok := c.get("example.com")
+	if !ok {
+		t.Fatal("get returned ok = false after refreshing an existing key")
+	}
+	if got["A"][0] != "2.2.2.2" {
+		t.Errorf("get returned %v, want refreshed value [2.2.2.2]", got)
+	}
+	if c.ll.Len() != 1 {
+		t.Errorf("cache has %d entries after refreshing an existing key, want 1", c.ll.Len())
+	}
+}
+
+func TestLRUCacheZeroCapacityUsesDefault(t *testing.T) {
+	c := newLRUCache(0)
+	if c.capacity != defaultCacheCapacity {
+		t.Errorf("newLRUCache(0) capacity = %d, want %d", c.capacity, defaultCacheCapacity)
+	}
+}
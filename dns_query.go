@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// queryMaxAttempts and queryRetryBaseDelay bound the retry-with-backoff
+// applied to each query in queryOverTransport: a query is retried on a
+// transport-level error (e.g. timeout) or a SERVFAIL response, with the
+// delay doubling each attempt.
+const (
+	queryMaxAttempts    = 3
+	queryRetryBaseDelay = 100 * time.Millisecond
+)
+
+// roundTripWithRetry sends query over transport, retrying on timeout or
+// SERVFAIL up to queryMaxAttempts times with exponential backoff.
+func roundTripWithRetry(ctx context.Context, transport Transport, query *dnsmessage.Message) (*dnsmessage.Message, error) {
+	var lastErr error
+	for attempt := 0; attempt < queryMaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(queryRetryBaseDelay * (1 << (attempt - 1)))
+		}
+
+		resp, err := transport.RoundTrip(ctx, query)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.RCode == dnsmessage.RCodeServerFailure {
+			lastErr = fmt.Errorf("server returned SERVFAIL")
+			continue
+		}
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+// queryTypes is the set of record types queried via raw DNS messages.
+var queryTypes = []dnsmessage.Type{
+	dnsmessage.TypeA,
+	dnsmessage.TypeAAAA,
+	dnsmessage.TypeCNAME,
+	dnsmessage.TypeMX,
+	dnsmessage.TypeTXT,
+	dnsmessage.TypeNS,
+}
+
+// buildQuery constructs a DNS query message for the given name and type. When
+// dnssecOK is set it attaches an EDNS0 OPT record with the DO bit so the
+// response includes any covering RRSIGs.
+func buildQuery(name string, qtype dnsmessage.Type, dnssecOK bool) (dnsmessage.Message, error) {
+	fqdn, err := dnsmessage.NewName(name + ".")
+	if err != nil {
+		return dnsmessage.Message{}, fmt.Errorf("invalid domain name %q: %w", name, err)
+	}
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               uint16(rand.Intn(1 << 16)),
+			RecursionDesired: true,
+		},
+		Questions: []dnsmessage.Question{
+			{Name: fqdn, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+	}
+
+	if dnssecOK {
+		var opt dnsmessage.Resource
+		if err := opt.Header.SetEDNS0(4096, dnsmessage.RCodeSuccess, true); err != nil {
+			return dnsmessage.Message{}, fmt.Errorf("setting EDNS0: %w", err)
+		}
+		opt.Body = &dnsmessage.OPTResource{}
+		msg.Additionals = []dnsmessage.Resource{opt}
+	}
+
+	return msg, nil
+}
+
+// validateResponse checks that a response matches the query it answers.
+func validateResponse(query, resp *dnsmessage.Message) error {
+	if resp.Header.ID != query.Header.ID {
+		return fmt.Errorf("response ID %d does not match query ID %d", resp.Header.ID, query.Header.ID)
+	}
+	if len(resp.Questions) != 1 || resp.Questions[0].Name.String() != query.Questions[0].Name.String() ||
+		resp.Questions[0].Type != query.Questions[0].Type {
+		return fmt.Errorf("response question does not match query")
+	}
+	return nil
+}
+
+// recordsFromResponse extracts the answer resources into the existing
+// map[string][]string shape used across the resolver.
+func recordsFromResponse(resp *dnsmessage.Message) map[string][]string {
+	records := make(map[string][]string)
+	for _, answer := range resp.Answers {
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			ip := net.IP(body.A[:])
+			records["A"] = append(records["A"], ip.String())
+		case *dnsmessage.AAAAResource:
+			ip := net.IP(body.AAAA[:])
+			records["AAAA"] = append(records["AAAA"], ip.String())
+		case *dnsmessage.CNAMEResource:
+			records["CNAME"] = append(records["CNAME"], strings.TrimSuffix(body.CNAME.String(), "."))
+		case *dnsmessage.MXResource:
+			records["MX"] = append(records["MX"], fmt.Sprintf("%s (Priority: %d)", strings.TrimSuffix(body.MX.String(), "."), body.Pref))
+		case *dnsmessage.TXTResource:
+			records["TXT"] = append(records["TXT"], strings.Join(body.TXT, ""))
+		case *dnsmessage.NSResource:
+			records["NS"] = append(records["NS"], strings.TrimSuffix(body.NS.String(), "."))
+		}
+	}
+	return records
+}
+
+// queryOverTransport resolves an already-normalized domain by sending one raw
+// DNS query per record type over the given Transport (UDP, TCP, DoT, DoH, or
+// a racing combination of servers), instead of relying on net.Lookup*.
+// dnssecOK requests RRSIGs alongside the records via the EDNS0 DO bit. Each
+// query is retried with backoff on timeout or SERVFAIL via
+// roundTripWithRetry. It returns the resolved records, a human-readable
+// error per failed query (for formats like JSON that surface errors to the
+// caller), and the lowest TTL seen across all answers, for the cache.
+func queryOverTransport(domain string, transport Transport, dnssecOK bool) (map[string][]string, []string, uint32) {
+	records := make(map[string][]string)
+	var errs []string
+	var ttl uint32
+	ttlSet := false
+
+	for _, qtype := range queryTypes {
+		query, err := buildQuery(domain, qtype, dnssecOK)
+		if err != nil {
+			log.Printf("Error building query for %s: %v\n", qtype, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", qtype, err))
+			continue
+		}
+
+		resp, err := roundTripWithRetry(context.Background(), transport, &query)
+		if err != nil {
+			log.Printf("Error querying %s records for %s: %v\n", qtype, domain, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", qtype, err))
+			continue
+		}
+		if err := validateResponse(&query, resp); err != nil {
+			log.Printf("Invalid response for %s records of %s: %v\n", qtype, domain, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", qtype, err))
+			continue
+		}
+		if resp.RCode == dnsmessage.RCodeNameError {
+			errs = append(errs, fmt.Sprintf("%s: NXDOMAIN", qtype))
+			continue
+		}
+
+		for k, v := range recordsFromResponse(resp) {
+			records[k] = append(records[k], v...)
+		}
+		for _, answer := range resp.Answers {
+			if !ttlSet || answer.Header.TTL < ttl {
+				ttl = answer.Header.TTL
+				ttlSet = true
+			}
+		}
+	}
+
+	return records, errs, ttl
+}
+
+// reverseAddrName builds the in-addr.arpa (IPv4) or ip6.arpa (IPv6) name used
+// for PTR lookups, mirroring the stdlib's reverse-address construction.
+func reverseAddrName(ipStr string) (string, error) {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address %q", ipStr)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip4[3], ip4[2], ip4[1], ip4[0]), nil
+	}
+
+	const hexDigit = "0123456789abcdef"
+	var buf strings.Builder
+	for i := len(ip) - 1; i >= 0; i-- {
+		b := ip[i]
+		buf.WriteByte(hexDigit[b&0xF])
+		buf.WriteByte('.')
+		buf.WriteByte(hexDigit[b>>4])
+		buf.WriteByte('.')
+	}
+	buf.WriteString("ip6.arpa")
+	return buf.String(), nil
+}
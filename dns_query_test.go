@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestReverseAddrName(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+		want string
+	}{
+		{"ipv4", "192.0.2.1", "1.2.0.192.in-addr.arpa"},
+		{"ipv4 loopback", "127.0.0.1", "1.0.0.127.in-addr.arpa"},
+		{"ipv6 loopback", "::1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.ip6.arpa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := reverseAddrName(tt.ip)
+			if err != nil {
+				t.Fatalf("reverseAddrName(%q) returned error: %v", tt.ip, err)
+			}
+			if got != tt.want {
+				t.Errorf("reverseAddrName(%q) = %q, want %q", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReverseAddrNameInvalid(t *testing.T) {
+	if _, err := reverseAddrName("not-an-ip"); err == nil {
+		t.Error("reverseAddrName(\"not-an-ip\") returned no error, want one")
+	}
+}
+
+func TestBuildQuery(t *testing.T) {
+	query, err := buildQuery("example.com", dnsmessage.TypeA, false)
+	if err != nil {
+		t.Fatalf("buildQuery returned error: %v", err)
+	}
+	if len(query.Questions) != 1 {
+		t.Fatalf("query has %d questions, want 1", len(query.Questions))
+	}
+	if query.Questions[0].Name.String() != "example.com." {
+		t.Errorf("question name = %q, want %q", query.Questions[0].Name.String(), "example.com.")
+	}
+	if query.Questions[0].Type != dnsmessage.TypeA {
+		t.Errorf("question type = %v, want %v", query.Questions[0].Type, dnsmessage.TypeA)
+	}
+	if len(query.Additionals) != 0 {
+		t.Errorf("query has %d additionals without dnssecOK, want 0", len(query.Additionals))
+	}
+}
+
+func TestBuildQueryDNSSECOK(t *testing.T) {
+	query, err := buildQuery("example.com", dnsmessage.TypeA, true)
+	if err != nil {
+		t.Fatalf("buildQuery returned error: %v", err)
+	}
+	if len(query.Additionals) != 1 {
+		t.Fatalf("query has %d additionals with dnssecOK, want 1 (EDNS0 OPT)", len(query.Additionals))
+	}
+	if _, ok := query.Additionals[0].Body.(*dnsmessage.OPTResource); !ok {
+		t.Errorf("additional record is %T, want *dnsmessage.OPTResource", query.Additionals[0].Body)
+	}
+}
+
+func TestBuildQueryInvalidName(t *testing.T) {
+	bad := make([]byte, 300)
+	for i := range bad {
+		bad[i] = 'a'
+	}
+	if _, err := buildQuery(string(bad), dnsmessage.TypeA, false); err == nil {
+		t.Error("buildQuery with an oversized name returned no error, want one")
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	query, err := buildQuery("example.com", dnsmessage.TypeA, false)
+	if err != nil {
+		t.Fatalf("buildQuery returned error: %v", err)
+	}
+
+	match := query
+	match.Header.Response = true
+	if err := validateResponse(&query, &match); err != nil {
+		t.Errorf("validateResponse with a matching response returned error: %v", err)
+	}
+
+	mismatchedID := query
+	mismatchedID.Header.ID = query.Header.ID + 1
+	if err := validateResponse(&query, &mismatchedID); err == nil {
+		t.Error("validateResponse with a mismatched ID returned no error, want one")
+	}
+
+	other, err := buildQuery("example.org", dnsmessage.TypeA, false)
+	if err != nil {
+		t.Fatalf("buildQuery returned error: %v", err)
+	}
+	other.Header.ID = query.Header.ID
+	if err := validateResponse(&query, &other); err == nil {
+		t.Error("validateResponse with a mismatched question returned no error, want one")
+	}
+}
+
+func mustName(t *testing.T, s string) dnsmessage.Name {
+	t.Helper()
+	name, err := dnsmessage.NewName(s)
+	if err != nil {
+		t.Fatalf("dnsmessage.NewName(%q) returned error: %v", s, err)
+	}
+	return name
+}
+
+func TestRecordsFromResponse(t *testing.T) {
+	owner := mustName(t, "example.com.")
+	resp := &dnsmessage.Message{
+		Answers: []dnsmessage.Resource{
+			{
+				Header: dnsmessage.ResourceHeader{Name: owner, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET},
+				Body:   &dnsmessage.AResource{A: [4]byte(net.ParseIP("93.184.216.34").To4())},
+			},
+			{
+				Header: dnsmessage.ResourceHeader{Name: owner, Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET},
+				Body:   &dnsmessage.CNAMEResource{CNAME: mustName(t, "alias.example.com.")},
+			},
+			{
+				Header: dnsmessage.ResourceHeader{Name: owner, Type: dnsmessage.TypeMX, Class: dnsmessage.ClassINET},
+				Body:   &dnsmessage.MXResource{Pref: 10, MX: mustName(t, "mail.example.com.")},
+			},
+			{
+				Header: dnsmessage.ResourceHeader{Name: owner, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET},
+				Body:   &dnsmessage.TXTResource{TXT: []string{"v=spf1 ", "-all"}},
+			},
+		},
+	}
+
+	records := recordsFromResponse(resp)
+
+	if got := records["A"]; len(got) != 1 || got[0] != "93.184.216.34" {
+		t.Errorf("A records = %v, want [93.184.216.34]", got)
+	}
+	if got := records["CNAME"]; len(got) != 1 || got[0] != "alias.example.com" {
+		t.Errorf("CNAME records = %v, want [alias.example.com]", got)
+	}
+	if got := records["MX"]; len(got) != 1 || got[0] != "mail.example.com (Priority: 10)" {
+		t.Errorf("MX records = %v, want [mail.example.com (Priority: 10)]", got)
+	}
+	if got := records["TXT"]; len(got) != 1 || got[0] != "v=spf1 -all" {
+		t.Errorf("TXT records = %v, want [v=spf1 -all]", got)
+	}
+}
@@ -0,0 +1,854 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Record types dnsmessage doesn't model natively; their Answers come back as
+// dnsmessage.UnknownResource, which we parse ourselves per RFC 4034.
+const (
+	typeDS     = dnsmessage.Type(43)
+	typeRRSIG  = dnsmessage.Type(46)
+	typeNSEC   = dnsmessage.Type(47)
+	typeDNSKEY = dnsmessage.Type(48)
+	typeNSEC3  = dnsmessage.Type(50)
+)
+
+// DNSSEC signing algorithms this resolver can verify (RFC 8624 minimum set).
+const (
+	algRSASHA256       = 8
+	algECDSAP256SHA256 = 13
+	algED25519         = 15
+)
+
+// defaultDNSSECResolver is used when --dnssec is given without --server,
+// --dot or --doh, since net.Lookup* cannot return RRSIGs or the AD bit.
+const defaultDNSSECResolver = "1.1.1.1"
+
+// rootTrustAnchorKeyTag and rootTrustAnchorDigest identify the IANA root
+// zone KSK (KSK-2017), the hardcoded trust anchor the chain walk bottoms
+// out at. Published at https://data.iana.org/root-anchors/root-anchors.xml.
+const (
+	rootTrustAnchorKeyTag  = 20326
+	rootTrustAnchorDigest  = "E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"
+	rootTrustAnchorDigType = 2 // SHA-256
+)
+
+type dnskeyRecord struct {
+	Flags     uint16
+	Protocol  uint8
+	Algorithm uint8
+	PublicKey []byte
+	raw       []byte // original wire-format RDATA, needed for key tag and DS hashing
+}
+
+type rrsigRecord struct {
+	TypeCovered uint16
+	Algorithm   uint8
+	Labels      uint8
+	OriginalTTL uint32
+	Expiration  uint32
+	Inception   uint32
+	KeyTag      uint16
+	SignerName  string
+	Signature   []byte
+}
+
+type dsRecord struct {
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     []byte
+}
+
+// parseDNSKEY parses a DNSKEY RDATA per RFC 4034 section 2.
+func parseDNSKEY(data []byte) (dnskeyRecord, error) {
+	if len(data) < 4 {
+		return dnskeyRecord{}, fmt.Errorf("DNSKEY record too short")
+	}
+	return dnskeyRecord{
+		Flags:     binary.BigEndian.Uint16(data[0:2]),
+		Protocol:  data[2],
+		Algorithm: data[3],
+		PublicKey: data[4:],
+		raw:       data,
+	}, nil
+}
+
+// parseRRSIG parses an RRSIG RDATA per RFC 4034 section 3. The signer name is
+// always uncompressed within RRSIG RDATA.
+func parseRRSIG(data []byte) (rrsigRecord, error) {
+	if len(data) < 19 {
+		return rrsigRecord{}, fmt.Errorf("RRSIG record too short")
+	}
+	rec := rrsigRecord{
+		TypeCovered: binary.BigEndian.Uint16(data[0:2]),
+		Algorithm:   data[2],
+		Labels:      data[3],
+		OriginalTTL: binary.BigEndian.Uint32(data[4:8]),
+		Expiration:  binary.BigEndian.Uint32(data[8:12]),
+		Inception:   binary.BigEndian.Uint32(data[12:16]),
+		KeyTag:      binary.BigEndian.Uint16(data[16:18]),
+	}
+
+	name, off, err := unpackUncompressedName(data, 18)
+	if err != nil {
+		return rrsigRecord{}, fmt.Errorf("parsing RRSIG signer name: %w", err)
+	}
+	rec.SignerName = name
+	rec.Signature = data[off:]
+	return rec, nil
+}
+
+// parseDS parses a DS RDATA per RFC 4034 section 5.
+func parseDS(data []byte) (dsRecord, error) {
+	if len(data) < 4 {
+		return dsRecord{}, fmt.Errorf("DS record too short")
+	}
+	return dsRecord{
+		KeyTag:     binary.BigEndian.Uint16(data[0:2]),
+		Algorithm:  data[2],
+		DigestType: data[3],
+		Digest:     data[4:],
+	}, nil
+}
+
+// unpackUncompressedName reads a length-prefixed domain name with no
+// pointer compression, as used inside RRSIG RDATA.
+func unpackUncompressedName(data []byte, off int) (string, int, error) {
+	var sb strings.Builder
+	for {
+		if off >= len(data) {
+			return "", 0, fmt.Errorf("truncated name")
+		}
+		l := int(data[off])
+		off++
+		if l == 0 {
+			break
+		}
+		if off+l > len(data) {
+			return "", 0, fmt.Errorf("truncated name label")
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte('.')
+		}
+		sb.Write(data[off : off+l])
+		off += l
+	}
+	return sb.String(), off, nil
+}
+
+// canonicalWireName renders name as lowercase, uncompressed wire-format
+// labels terminated by a root label, per RFC 4034 section 6.2.
+func canonicalWireName(name string) []byte {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	var buf []byte
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			buf = append(buf, byte(len(label)))
+			buf = append(buf, label...)
+		}
+	}
+	return append(buf, 0)
+}
+
+// canonicalRDATA returns the canonical wire-format RDATA for a parsed
+// resource body, reconstructing embedded names without compression.
+func canonicalRDATA(body dnsmessage.ResourceBody) ([]byte, error) {
+	switch b := body.(type) {
+	case *dnsmessage.AResource:
+		return append([]byte(nil), b.A[:]...), nil
+	case *dnsmessage.AAAAResource:
+		return append([]byte(nil), b.AAAA[:]...), nil
+	case *dnsmessage.CNAMEResource:
+		return canonicalWireName(b.CNAME.String()), nil
+	case *dnsmessage.NSResource:
+		return canonicalWireName(b.NS.String()), nil
+	case *dnsmessage.MXResource:
+		buf := make([]byte, 2)
+		binary.BigEndian.PutUint16(buf, b.Pref)
+		return append(buf, canonicalWireName(b.MX.String())...), nil
+	case *dnsmessage.TXTResource:
+		var buf []byte
+		for _, s := range b.TXT {
+			buf = append(buf, byte(len(s)))
+			buf = append(buf, s...)
+		}
+		return buf, nil
+	case *dnsmessage.UnknownResource:
+		return append([]byte(nil), b.Data...), nil
+	default:
+		return nil, fmt.Errorf("unsupported resource type for DNSSEC canonicalization")
+	}
+}
+
+// nsecRecord is the part of an NSEC RDATA (RFC 4034 section 4) this resolver
+// needs: the next owner name in the zone's canonical ordering. The type
+// bitmap that follows is irrelevant to proving non-existence of a name, so
+// it's left unparsed.
+type nsecRecord struct {
+	NextDomain string
+}
+
+// parseNSEC parses an NSEC RDATA per RFC 4034 section 4.
+func parseNSEC(data []byte) (nsecRecord, error) {
+	name, _, err := unpackUncompressedName(data, 0)
+	if err != nil {
+		return nsecRecord{}, fmt.Errorf("parsing NSEC next domain name: %w", err)
+	}
+	return nsecRecord{NextDomain: name}, nil
+}
+
+// nsec3Record is the part of an NSEC3 RDATA (RFC 5155 section 3) this
+// resolver needs to walk the hashed name chain; the type bitmap is unparsed
+// for the same reason as nsecRecord's.
+type nsec3Record struct {
+	Iterations      uint16
+	Salt            []byte
+	NextHashedOwner []byte
+}
+
+// parseNSEC3 parses an NSEC3 RDATA per RFC 5155 section 3.2.
+func parseNSEC3(data []byte) (nsec3Record, error) {
+	if len(data) < 5 {
+		return nsec3Record{}, fmt.Errorf("NSEC3 record too short")
+	}
+	iterations := binary.BigEndian.Uint16(data[2:4])
+	saltLen := int(data[4])
+	off := 5
+	if len(data) < off+saltLen+1 {
+		return nsec3Record{}, fmt.Errorf("NSEC3 record too short for salt")
+	}
+	salt := data[off : off+saltLen]
+	off += saltLen
+
+	hashLen := int(data[off])
+	off++
+	if len(data) < off+hashLen {
+		return nsec3Record{}, fmt.Errorf("NSEC3 record too short for next hashed owner")
+	}
+	return nsec3Record{
+		Iterations:      iterations,
+		Salt:            salt,
+		NextHashedOwner: data[off : off+hashLen],
+	}, nil
+}
+
+// nsec3Hash computes the NSEC3 hashed owner name for name per RFC 5155
+// section 5: an initial SHA-1 hash of the canonical wire-format name salted,
+// then iterations additional rounds of salted re-hashing. SHA-1 (hash
+// algorithm 1) is the only algorithm NSEC3 defines.
+func nsec3Hash(name string, salt []byte, iterations uint16) []byte {
+	digest := canonicalWireName(name)
+	for i := 0; i <= int(iterations); i++ {
+		sum := sha1.Sum(append(append([]byte(nil), digest...), salt...))
+		digest = sum[:]
+	}
+	return digest
+}
+
+// base32HexDecode decodes an NSEC3 owner name's first label, which is the
+// hashed owner name base32hex-encoded per RFC 5155 section 1.3.
+func base32HexDecode(s string) ([]byte, error) {
+	return base32.HexEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(s))
+}
+
+// canonicalNameLabels splits name into its labels, lowercased and in
+// right-to-left (most significant label first) order, per the canonical DNS
+// name ordering defined in RFC 4034 section 6.1.
+func canonicalNameLabels(name string) []string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name == "" {
+		return nil
+	}
+	labels := strings.Split(name, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return labels
+}
+
+// canonicalNameLess reports whether a sorts before b in canonical DNS name
+// ordering.
+func canonicalNameLess(a, b string) bool {
+	al, bl := canonicalNameLabels(a), canonicalNameLabels(b)
+	for i := 0; i < len(al) && i < len(bl); i++ {
+		if al[i] != bl[i] {
+			return al[i] < bl[i]
+		}
+	}
+	return len(al) < len(bl)
+}
+
+// nsecCovers reports whether name falls strictly between owner and next in
+// canonical ordering, i.e. the NSEC record "owner NSEC next" proves name
+// doesn't exist. The last NSEC in a zone wraps around to the zone apex, so
+// next <= owner is treated as covering everything after owner too.
+func nsecCovers(name, owner, next string) bool {
+	if canonicalNameLess(owner, next) {
+		return canonicalNameLess(owner, name) && canonicalNameLess(name, next)
+	}
+	return canonicalNameLess(owner, name) || canonicalNameLess(name, next)
+}
+
+// nsec3Covers reports whether hash falls strictly between ownerHash and
+// nextHash in hash order, with the same end-of-chain wraparound as
+// nsecCovers.
+func nsec3Covers(hash, ownerHash, nextHash []byte) bool {
+	if bytes.Compare(ownerHash, nextHash) < 0 {
+		return bytes.Compare(ownerHash, hash) < 0 && bytes.Compare(hash, nextHash) < 0
+	}
+	return bytes.Compare(ownerHash, hash) < 0 || bytes.Compare(hash, nextHash) < 0
+}
+
+// denialProof is an NSEC or NSEC3 RRset from a negative response's authority
+// section, together with the RRSIGs covering it, ready for signature
+// verification via verifyRRsetSignature.
+type denialProof struct {
+	owner  string
+	qtype  dnsmessage.Type
+	set    signedRRset
+	covers bool
+}
+
+// collectDenialProofs scans a negative response's authority section for
+// NSEC and NSEC3 RRsets (plus their RRSIGs) and reports, per owner name,
+// whether that record proves name doesn't exist.
+func collectDenialProofs(name string, authorities []dnsmessage.Resource) []denialProof {
+	bySet := make(map[string]*denialProof)
+	order := make([]string, 0, len(authorities))
+
+	key := func(owner string, qtype dnsmessage.Type) string {
+		return fmt.Sprintf("%d/%s", qtype, strings.ToLower(owner))
+	}
+
+	for _, rr := range authorities {
+		unk, ok := rr.Body.(*dnsmessage.UnknownResource)
+		if !ok {
+			continue
+		}
+		owner := rr.Header.Name.String()
+
+		switch rr.Header.Type {
+		case typeNSEC:
+			nsec, err := parseNSEC(unk.Data)
+			if err != nil {
+				continue
+			}
+			k := key(owner, typeNSEC)
+			if _, seen := bySet[k]; !seen {
+				bySet[k] = &denialProof{owner: owner, qtype: typeNSEC, covers: nsecCovers(name, owner, nsec.NextDomain)}
+				order = append(order, k)
+			}
+			bySet[k].set.rdatas = append(bySet[k].set.rdatas, append([]byte(nil), unk.Data...))
+
+		case typeNSEC3:
+			nsec3, err := parseNSEC3(unk.Data)
+			if err != nil {
+				continue
+			}
+			ownerHash, err := base32HexDecode(strings.SplitN(owner, ".", 2)[0])
+			if err != nil {
+				continue
+			}
+			nameHash := nsec3Hash(name, nsec3.Salt, nsec3.Iterations)
+			k := key(owner, typeNSEC3)
+			if _, seen := bySet[k]; !seen {
+				bySet[k] = &denialProof{owner: owner, qtype: typeNSEC3, covers: nsec3Covers(nameHash, ownerHash, nsec3.NextHashedOwner)}
+				order = append(order, k)
+			}
+			bySet[k].set.rdatas = append(bySet[k].set.rdatas, append([]byte(nil), unk.Data...))
+
+		case typeRRSIG:
+			sig, err := parseRRSIG(unk.Data)
+			if err != nil {
+				continue
+			}
+			covered := dnsmessage.Type(sig.TypeCovered)
+			if covered != typeNSEC && covered != typeNSEC3 {
+				continue
+			}
+			k := key(owner, covered)
+			if _, seen := bySet[k]; !seen {
+				bySet[k] = &denialProof{owner: owner, qtype: covered}
+				order = append(order, k)
+			}
+			bySet[k].set.rrsigs = append(bySet[k].set.rrsigs, sig)
+		}
+	}
+
+	proofs := make([]denialProof, 0, len(order))
+	for _, k := range order {
+		proofs = append(proofs, *bySet[k])
+	}
+	return proofs
+}
+
+// dnssecStatusForNegative reports the DNSSEC validation status of a negative
+// (NXDOMAIN/NODATA) answer for domain, per RFC 4035/5155: it looks for an
+// NSEC or NSEC3 record in the authority section that covers domain, verifies
+// that record's RRSIG against the zone's DNSKEYs, and walks the chain of
+// trust the same way dnssecStatusForType does for positive answers.
+func dnssecStatusForNegative(domain string, transport Transport) string {
+	query, err := buildQuery(domain, dnsmessage.TypeA, true)
+	if err != nil {
+		return "Bogus"
+	}
+	resp, err := transport.RoundTrip(context.Background(), &query)
+	if err != nil {
+		return "Bogus"
+	}
+
+	proofs := collectDenialProofs(domain, resp.Authorities)
+	if len(proofs) == 0 {
+		return "Insecure"
+	}
+
+	for _, proof := range proofs {
+		if !proof.covers {
+			continue
+		}
+
+		// The zone holding the NSEC/NSEC3 record is its RRSIG's signer, not
+		// the record's owner name.
+		if len(proof.set.rrsigs) == 0 {
+			return "Bogus"
+		}
+		zone := proof.set.rrsigs[0].SignerName
+
+		keySet, err := fetchCachedDNSKEYs(zone, transport)
+		if err != nil || len(keySet.rdatas) == 0 {
+			return "Bogus"
+		}
+		keys := parseDNSKEYs(keySet.rdatas)
+
+		if _, err := verifyRRsetSignature(proof.owner, proof.qtype, proof.set, keys); err != nil {
+			return "Bogus"
+		}
+
+		trusted, err := validateChainToRoot(zone, transport)
+		if err != nil {
+			return "Bogus"
+		}
+		if !trusted {
+			return "Insecure"
+		}
+		return "AD"
+	}
+
+	// NSEC/NSEC3 records were present but none actually covered the queried
+	// name - the server didn't prove what it claims to.
+	return "Bogus"
+}
+
+// signedRRset is an RRset plus the RRSIGs covering it, with RDATA already in
+// canonical wire form.
+type signedRRset struct {
+	rdatas [][]byte
+	rrsigs []rrsigRecord
+}
+
+// fetchSignedRRset queries name/qtype with the DO bit set and splits the
+// answer into the covered RRset and its RRSIGs.
+func fetchSignedRRset(name string, qtype dnsmessage.Type, transport Transport) (signedRRset, error) {
+	query, err := buildQuery(name, qtype, true)
+	if err != nil {
+		return signedRRset{}, err
+	}
+
+	resp, err := transport.RoundTrip(context.Background(), &query)
+	if err != nil {
+		return signedRRset{}, err
+	}
+
+	var set signedRRset
+	for _, answer := range resp.Answers {
+		switch answer.Header.Type {
+		case qtype:
+			rd, err := canonicalRDATA(answer.Body)
+			if err != nil {
+				continue
+			}
+			set.rdatas = append(set.rdatas, rd)
+		case typeRRSIG:
+			unk, ok := answer.Body.(*dnsmessage.UnknownResource)
+			if !ok {
+				continue
+			}
+			sig, err := parseRRSIG(unk.Data)
+			if err != nil {
+				continue
+			}
+			if dnsmessage.Type(sig.TypeCovered) == qtype {
+				set.rrsigs = append(set.rrsigs, sig)
+			}
+		}
+	}
+	return set, nil
+}
+
+// parseDNSKEYs parses DNSKEY records out of already-canonicalized RDATA.
+func parseDNSKEYs(rdatas [][]byte) []dnskeyRecord {
+	keys := make([]dnskeyRecord, 0, len(rdatas))
+	for _, rd := range rdatas {
+		key, err := parseDNSKEY(rd)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// computeKeyTag implements the key tag algorithm from RFC 4034 appendix B
+// (valid for every algorithm this resolver supports).
+func computeKeyTag(rdata []byte) uint16 {
+	var ac uint32
+	for i, b := range rdata {
+		if i&1 == 0 {
+			ac += uint32(b) << 8
+		} else {
+			ac += uint32(b)
+		}
+	}
+	ac += (ac >> 16) & 0xFFFF
+	return uint16(ac & 0xFFFF)
+}
+
+// rrsigSignedData builds the exact byte sequence an RRSIG signs: the RRSIG
+// RDATA (without the signature) followed by the covered RRset in canonical
+// form, RRs ordered by their canonical RDATA (RFC 4034 section 3.1.8.1, 6.3).
+func rrsigSignedData(owner string, qtype dnsmessage.Type, sig rrsigRecord, rdatas [][]byte) []byte {
+	var buf bytes.Buffer
+
+	hdr := make([]byte, 18)
+	binary.BigEndian.PutUint16(hdr[0:2], sig.TypeCovered)
+	hdr[2] = sig.Algorithm
+	hdr[3] = sig.Labels
+	binary.BigEndian.PutUint32(hdr[4:8], sig.OriginalTTL)
+	binary.BigEndian.PutUint32(hdr[8:12], sig.Expiration)
+	binary.BigEndian.PutUint32(hdr[12:16], sig.Inception)
+	binary.BigEndian.PutUint16(hdr[16:18], sig.KeyTag)
+	buf.Write(hdr)
+	buf.Write(canonicalWireName(sig.SignerName))
+
+	ownerWire := canonicalWireName(owner)
+	typeBuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(typeBuf, uint16(qtype))
+	ttlBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(ttlBuf, sig.OriginalTTL)
+
+	sorted := append([][]byte(nil), rdatas...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+
+	for _, rd := range sorted {
+		buf.Write(ownerWire)
+		buf.Write(typeBuf)
+		buf.Write([]byte{0, 1}) // CLASS IN
+		buf.Write(ttlBuf)
+		rdlen := make([]byte, 2)
+		binary.BigEndian.PutUint16(rdlen, uint16(len(rd)))
+		buf.Write(rdlen)
+		buf.Write(rd)
+	}
+	return buf.Bytes()
+}
+
+// parseRSAPublicKey decodes a DNSKEY public key field for algorithm 8
+// (RSA/SHA-256), per RFC 3110.
+func parseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	if len(data) < 3 {
+		return nil, fmt.Errorf("RSA key too short")
+	}
+	expLen := int(data[0])
+	off := 1
+	if expLen == 0 {
+		expLen = int(data[1])<<8 | int(data[2])
+		off = 3
+	}
+	if len(data) < off+expLen {
+		return nil, fmt.Errorf("RSA key too short for exponent")
+	}
+	e := new(big.Int).SetBytes(data[off : off+expLen])
+	n := new(big.Int).SetBytes(data[off+expLen:])
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+// verifySignature verifies signedData against signature using key, per the
+// wire formats defined for algorithms 8 (RSA/SHA-256), 13 (ECDSA P-256/
+// SHA-256) and 15 (Ed25519).
+func verifySignature(algorithm uint8, signedData, signature []byte, key dnskeyRecord) error {
+	switch algorithm {
+	case algRSASHA256:
+		pub, err := parseRSAPublicKey(key.PublicKey)
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(signedData)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], signature)
+
+	case algECDSAP256SHA256:
+		if len(key.PublicKey) != 64 || len(signature) != 64 {
+			return fmt.Errorf("unexpected ECDSA key or signature length")
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(key.PublicKey[:32]),
+			Y:     new(big.Int).SetBytes(key.PublicKey[32:]),
+		}
+		hash := sha256.Sum256(signedData)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, hash[:], r, s) {
+			return fmt.Errorf("ECDSA signature verification failed")
+		}
+		return nil
+
+	case algED25519:
+		if len(key.PublicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("unexpected Ed25519 key length")
+		}
+		if len(signature) != ed25519.SignatureSize {
+			return fmt.Errorf("unexpected Ed25519 signature length")
+		}
+		if !ed25519.Verify(ed25519.PublicKey(key.PublicKey), signedData, signature) {
+			return fmt.Errorf("Ed25519 signature verification failed")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported DNSSEC algorithm %d", algorithm)
+	}
+}
+
+// verifyRRsetSignature finds an RRSIG over set whose signer key is present in
+// keys and whose signature verifies, returning the key that validated it.
+func verifyRRsetSignature(owner string, qtype dnsmessage.Type, set signedRRset, keys []dnskeyRecord) (*dnskeyRecord, error) {
+	if len(set.rrsigs) == 0 {
+		return nil, fmt.Errorf("no RRSIG covering %s %s", owner, qtype)
+	}
+
+	var lastErr error
+	for _, sig := range set.rrsigs {
+		for i := range keys {
+			key := keys[i]
+			if key.Algorithm != sig.Algorithm || computeKeyTag(key.raw) != sig.KeyTag {
+				continue
+			}
+			signedData := rrsigSignedData(owner, qtype, sig, set.rdatas)
+			if err := verifySignature(sig.Algorithm, signedData, sig.Signature, key); err != nil {
+				lastErr = err
+				continue
+			}
+			return &key, nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no DNSKEY matches the RRSIG covering %s %s", owner, qtype)
+	}
+	return nil, lastErr
+}
+
+// dsDigestMatches reports whether ds is the published digest of the DNSKEY
+// with RDATA dnskeyRDATA owned by owner (RFC 4034 section 5.1.4).
+func dsDigestMatches(owner string, dnskeyRDATA []byte, ds dsRecord) bool {
+	if ds.DigestType != rootTrustAnchorDigType {
+		return false
+	}
+	h := sha256.New()
+	h.Write(canonicalWireName(owner))
+	h.Write(dnskeyRDATA)
+	return bytes.Equal(h.Sum(nil), ds.Digest)
+}
+
+func parentZone(name string) string {
+	name = strings.TrimSuffix(name, ".")
+	if idx := strings.Index(name, "."); idx != -1 {
+		return name[idx+1:]
+	}
+	return ""
+}
+
+var (
+	zoneTrustCache = make(map[string]bool)
+	zoneTrustMutex sync.Mutex
+)
+
+var (
+	dnskeyCache = make(map[string]signedRRset)
+	dnskeyMutex sync.Mutex
+)
+
+// fetchCachedDNSKEYs returns zone's DNSKEY RRset and its RRSIGs as fetched
+// (their signatures are not checked here; callers verify them), querying the
+// network only once per zone: dnssecStatusForType/dnssecStatusForNegative and
+// computeChainTrust all need the same zone's DNSKEYs, so without this a
+// domain with N record types would repeat the same DNSKEY query N times.
+func fetchCachedDNSKEYs(zone string, transport Transport) (signedRRset, error) {
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+
+	dnskeyMutex.Lock()
+	if set, ok := dnskeyCache[zone]; ok {
+		dnskeyMutex.Unlock()
+		return set, nil
+	}
+	dnskeyMutex.Unlock()
+
+	set, err := fetchSignedRRset(zone, typeDNSKEY, transport)
+	if err != nil {
+		return signedRRset{}, err
+	}
+
+	dnskeyMutex.Lock()
+	dnskeyCache[zone] = set
+	dnskeyMutex.Unlock()
+
+	return set, nil
+}
+
+// validateChainToRoot walks the delegation chain from zone up to the root,
+// verifying at each step that the zone's DNSKEY RRset is self-signed by one
+// of its own keys and that key's hash matches a DS record vouched for by the
+// (already-validated) parent zone, bottoming out at the IANA root trust
+// anchor. It only follows the immediate parent at each step, not a full
+// iterative walk through every intermediate nameserver.
+func validateChainToRoot(zone string, transport Transport) (bool, error) {
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+
+	zoneTrustMutex.Lock()
+	if trusted, ok := zoneTrustCache[zone]; ok {
+		zoneTrustMutex.Unlock()
+		return trusted, nil
+	}
+	zoneTrustMutex.Unlock()
+
+	trusted, err := computeChainTrust(zone, transport)
+
+	zoneTrustMutex.Lock()
+	zoneTrustCache[zone] = trusted
+	zoneTrustMutex.Unlock()
+
+	return trusted, err
+}
+
+func computeChainTrust(zone string, transport Transport) (bool, error) {
+	keySet, err := fetchCachedDNSKEYs(zone, transport)
+	if err != nil || len(keySet.rdatas) == 0 {
+		return false, nil
+	}
+	keys := parseDNSKEYs(keySet.rdatas)
+
+	ksk, err := verifyRRsetSignature(zone, typeDNSKEY, keySet, keys)
+	if err != nil {
+		return false, nil
+	}
+
+	if zone == "" {
+		digest := sha256.Sum256(append(canonicalWireName(zone), ksk.raw...))
+		trusted := computeKeyTag(ksk.raw) == rootTrustAnchorKeyTag &&
+			strings.EqualFold(hex.EncodeToString(digest[:]), rootTrustAnchorDigest)
+		return trusted, nil
+	}
+
+	parent := parentZone(zone)
+	parentTrusted, err := validateChainToRoot(parent, transport)
+	if err != nil || !parentTrusted {
+		return false, err
+	}
+
+	parentKeySet, err := fetchCachedDNSKEYs(parent, transport)
+	if err != nil {
+		return false, nil
+	}
+	parentKeys := parseDNSKEYs(parentKeySet.rdatas)
+
+	dsSet, err := fetchSignedRRset(zone, typeDS, transport)
+	if err != nil || len(dsSet.rdatas) == 0 {
+		return false, nil
+	}
+	if _, err := verifyRRsetSignature(zone, typeDS, dsSet, parentKeys); err != nil {
+		return false, err
+	}
+
+	for _, rd := range dsSet.rdatas {
+		ds, err := parseDS(rd)
+		if err != nil {
+			continue
+		}
+		if dsDigestMatches(zone, ksk.raw, ds) {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("no DS record at %s matches its DNSKEY", zone)
+}
+
+// dnssecStatusForType reports the DNSSEC validation status of domain's qtype
+// answer, using RFC 4035 terminology: "AD" (authenticated), "Insecure" (no
+// chain of trust, signed or not) or "Bogus" (signed but fails to validate).
+func dnssecStatusForType(domain string, qtype dnsmessage.Type, transport Transport) string {
+	set, err := fetchSignedRRset(domain, qtype, transport)
+	if err != nil {
+		return "Bogus"
+	}
+	if len(set.rrsigs) == 0 {
+		return "Insecure"
+	}
+
+	keySet, err := fetchCachedDNSKEYs(domain, transport)
+	if err != nil || len(keySet.rdatas) == 0 {
+		return "Bogus"
+	}
+	keys := parseDNSKEYs(keySet.rdatas)
+
+	if _, err := verifyRRsetSignature(domain, qtype, set, keys); err != nil {
+		return "Bogus"
+	}
+
+	trusted, err := validateChainToRoot(domain, transport)
+	if err != nil {
+		return "Bogus"
+	}
+	if !trusted {
+		return "Insecure"
+	}
+	return "AD"
+}
+
+// queryTypeForRecordType maps a printRecords record-type key back to the
+// dnsmessage.Type used to query it, for DNSSEC status lookups.
+func queryTypeForRecordType(recordType string) dnsmessage.Type {
+	switch recordType {
+	case "A":
+		return dnsmessage.TypeA
+	case "AAAA":
+		return dnsmessage.TypeAAAA
+	case "CNAME":
+		return dnsmessage.TypeCNAME
+	case "MX":
+		return dnsmessage.TypeMX
+	case "TXT":
+		return dnsmessage.TypeTXT
+	case "NS":
+		return dnsmessage.TypeNS
+	default:
+		return dnsmessage.TypeA
+	}
+}
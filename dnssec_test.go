@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// computeKeyTag, canonicalWireName and nsec3Hash test vectors below were
+// computed independently in Python against the algorithms in RFC 4034
+// appendix B and RFC 5155 section 5, not by calling this package's code, so
+// they catch implementation bugs rather than just re-asserting it.
+
+func TestComputeKeyTag(t *testing.T) {
+	tests := []struct {
+		name  string
+		rdata []byte
+		want  uint16
+	}{
+		{
+			name:  "even length",
+			rdata: []byte{0x01, 0x01, 0x03, 0x08, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF},
+			want:  27298,
+		},
+		{
+			name:  "36-byte RDATA with 32-byte key",
+			rdata: append([]byte{0x01, 0x00, 0x03, 0x08}, makeRange(32)...),
+			want:  62728,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := computeKeyTag(tt.rdata); got != tt.want {
+				t.Errorf("computeKeyTag(%x) = %d, want %d", tt.rdata, got, tt.want)
+			}
+		})
+	}
+}
+
+func makeRange(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+func TestCanonicalWireName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"mixed case with trailing dot", "Example.COM.", "076578616d706c6503636f6d00"},
+		{"root", "", "00"},
+		{"already lowercase, no trailing dot", "example.com", "076578616d706c6503636f6d00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hex.EncodeToString(canonicalWireName(tt.in))
+			if got != tt.want {
+				t.Errorf("canonicalWireName(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNsec3Hash(t *testing.T) {
+	salt, err := hex.DecodeString("aabbcc")
+	if err != nil {
+		t.Fatalf("decoding test salt: %v", err)
+	}
+	got := hex.EncodeToString(nsec3Hash("example.com", salt, 2))
+	want := "4f9aa5226d61819961295195421a23b8fa47dc7f"
+	if got != want {
+		t.Errorf("nsec3Hash(\"example.com\", %x, 2) = %s, want %s", salt, got, want)
+	}
+}
+
+func TestDsDigestMatches(t *testing.T) {
+	rdata := append([]byte{0x01, 0x00, 0x03, 0x08}, makeRange(32)...)
+	digestHex := "D7D2EBB8A3E6ACFF03F362CF4038AC555BF888685ECF0DD4718B9BAEDC1ECF37"
+	digest, err := hex.DecodeString(digestHex)
+	if err != nil {
+		t.Fatalf("decoding test digest: %v", err)
+	}
+
+	matching := dsRecord{DigestType: rootTrustAnchorDigType, Digest: digest}
+	if !dsDigestMatches("example.com", rdata, matching) {
+		t.Error("dsDigestMatches returned false for a digest computed from the same owner/RDATA")
+	}
+
+	wrongOwner := dsRecord{DigestType: rootTrustAnchorDigType, Digest: digest}
+	if dsDigestMatches("example.org", rdata, wrongOwner) {
+		t.Error("dsDigestMatches returned true for a digest computed from a different owner name")
+	}
+
+	unsupportedType := dsRecord{DigestType: 1, Digest: digest}
+	if dsDigestMatches("example.com", rdata, unsupportedType) {
+		t.Error("dsDigestMatches returned true for an unsupported digest type")
+	}
+}
+
+func TestNsecCovers(t *testing.T) {
+	tests := []struct {
+		name   string
+		qname  string
+		owner  string
+		next   string
+		covers bool
+	}{
+		{"name strictly between owner and next", "b.example.com", "a.example.com", "c.example.com", true},
+		{"name equals owner is not covered", "a.example.com", "a.example.com", "c.example.com", false},
+		{"name equals next is not covered", "c.example.com", "a.example.com", "c.example.com", false},
+		{"name outside the range", "z.example.com", "a.example.com", "c.example.com", false},
+		{"wraps around the end of the zone", "z.example.com", "x.example.com", "b.example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nsecCovers(tt.qname, tt.owner, tt.next); got != tt.covers {
+				t.Errorf("nsecCovers(%q, %q, %q) = %v, want %v", tt.qname, tt.owner, tt.next, got, tt.covers)
+			}
+		})
+	}
+}
+
+func TestNsec3Covers(t *testing.T) {
+	h := func(s string) []byte { b, _ := hex.DecodeString(s); return b }
+	tests := []struct {
+		name                      string
+		hash, ownerHash, nextHash string
+		covers                    bool
+	}{
+		{"within range", "50", "10", "90", true},
+		{"equals owner hash", "10", "10", "90", false},
+		{"equals next hash", "90", "10", "90", false},
+		{"outside range", "a0", "10", "90", false},
+		{"wraps around the end of the hash space", "a0", "90", "10", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nsec3Covers(h(tt.hash), h(tt.ownerHash), h(tt.nextHash)); got != tt.covers {
+				t.Errorf("nsec3Covers(%s, %s, %s) = %v, want %v", tt.hash, tt.ownerHash, tt.nextHash, got, tt.covers)
+			}
+		})
+	}
+}
+
+func TestParseDNSKEY(t *testing.T) {
+	data := append([]byte{0x01, 0x01, 0x03, 0x08}, 0xAA, 0xBB)
+	key, err := parseDNSKEY(data)
+	if err != nil {
+		t.Fatalf("parseDNSKEY returned error: %v", err)
+	}
+	if key.Flags != 0x0101 || key.Protocol != 3 || key.Algorithm != 8 {
+		t.Errorf("parseDNSKEY(%x) = %+v, want Flags=0x0101 Protocol=3 Algorithm=8", data, key)
+	}
+	if len(key.PublicKey) != 2 {
+		t.Errorf("parseDNSKEY(%x) public key length = %d, want 2", data, len(key.PublicKey))
+	}
+
+	if _, err := parseDNSKEY([]byte{0x01, 0x01}); err == nil {
+		t.Error("parseDNSKEY with a too-short RDATA returned no error, want one")
+	}
+}
+
+func TestParseDS(t *testing.T) {
+	data := []byte{0x4f, 0x66, 8, 2, 0xAA, 0xBB, 0xCC}
+	ds, err := parseDS(data)
+	if err != nil {
+		t.Fatalf("parseDS returned error: %v", err)
+	}
+	if ds.KeyTag != 0x4f66 || ds.Algorithm != 8 || ds.DigestType != 2 {
+		t.Errorf("parseDS(%x) = %+v, want KeyTag=0x4f66 Algorithm=8 DigestType=2", data, ds)
+	}
+	if len(ds.Digest) != 3 {
+		t.Errorf("parseDS(%x) digest length = %d, want 3", data, len(ds.Digest))
+	}
+
+	if _, err := parseDS([]byte{0x01}); err == nil {
+		t.Error("parseDS with a too-short RDATA returned no error, want one")
+	}
+}
+
+// TestRootTrustAnchorDigestIsValidSHA256 guards against the constant being
+// truncated or mistyped: a SHA-256 digest must decode to exactly 32 bytes,
+// and hex.DecodeString itself rejects an odd-length string outright.
+func TestRootTrustAnchorDigestIsValidSHA256(t *testing.T) {
+	decoded, err := hex.DecodeString(rootTrustAnchorDigest)
+	if err != nil {
+		t.Fatalf("rootTrustAnchorDigest is not valid hex: %v", err)
+	}
+	if len(decoded) != 32 {
+		t.Fatalf("rootTrustAnchorDigest decodes to %d bytes, want 32 (SHA-256)", len(decoded))
+	}
+}
+
+// countingDNSKEYTransport answers every query with a single DNSKEY-typed
+// record and counts how many times it was queried, so tests can assert on
+// fetchCachedDNSKEYs' network call count rather than just its return value.
+type countingDNSKEYTransport struct {
+	calls int
+}
+
+func (t *countingDNSKEYTransport) RoundTrip(ctx context.Context, query *dnsmessage.Message) (*dnsmessage.Message, error) {
+	t.calls++
+	return &dnsmessage.Message{
+		Answers: []dnsmessage.Resource{
+			{
+				Header: dnsmessage.ResourceHeader{Name: query.Questions[0].Name, Type: typeDNSKEY, Class: dnsmessage.ClassINET},
+				Body:   &dnsmessage.UnknownResource{Type: typeDNSKEY, Data: []byte{0x01, 0x01, 0x03, 0x08, 0xAA, 0xBB}},
+			},
+		},
+	}, nil
+}
+
+func TestFetchCachedDNSKEYsQueriesOncePerZone(t *testing.T) {
+	orig := dnskeyCache
+	dnskeyCache = make(map[string]signedRRset)
+	defer func() { dnskeyCache = orig }()
+
+	transport := &countingDNSKEYTransport{}
+
+	for i := 0; i < 3; i++ {
+		set, err := fetchCachedDNSKEYs("example.com", transport)
+		if err != nil {
+			t.Fatalf("fetchCachedDNSKEYs call %d returned error: %v", i, err)
+		}
+		if len(set.rdatas) != 1 {
+			t.Fatalf("fetchCachedDNSKEYs call %d returned %d rdatas, want 1", i, len(set.rdatas))
+		}
+	}
+	if transport.calls != 1 {
+		t.Errorf("transport was queried %d times for 3 lookups of the same zone, want 1", transport.calls)
+	}
+
+	if _, err := fetchCachedDNSKEYs("example.org", transport); err != nil {
+		t.Fatalf("fetchCachedDNSKEYs for a different zone returned error: %v", err)
+	}
+	if transport.calls != 2 {
+		t.Errorf("transport was queried %d times after a second zone, want 2", transport.calls)
+	}
+}
+
+// TestRootTrustAnchorDigestPinned pins rootTrustAnchorDigest against the
+// published IANA root zone KSK-2017 DS record
+// (https://data.iana.org/root-anchors/root-anchors.xml) so a future
+// transcription slip fails a test instead of silently making every
+// --dnssec chain walk bottom out as untrusted.
+func TestRootTrustAnchorDigestPinned(t *testing.T) {
+	const want = "E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"
+	if rootTrustAnchorDigest != want {
+		t.Fatalf("rootTrustAnchorDigest = %q, want %q", rootTrustAnchorDigest, want)
+	}
+	if rootTrustAnchorKeyTag != 20326 {
+		t.Fatalf("rootTrustAnchorKeyTag = %d, want 20326 (IANA KSK-2017)", rootTrustAnchorKeyTag)
+	}
+}
@@ -1,24 +1,19 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"net"
 	"os"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
+	"golang.org/x/net/dns/dnsmessage"
 	"golang.org/x/net/idna"
 )
 
-var dnsCache = make(map[string]struct {
-	records map[string][]string
-	expiry  time.Time
-})
-var cacheMutex sync.Mutex
-
 func initLogger() {
 	file, err := os.OpenFile("dns_resolver.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -37,36 +32,29 @@ func normalizeDomain(domain string) string {
 	return normalized
 }
 
-// cacheResult caches DNS records for a domain
-func cacheResult(domain string, records map[string][]string) {
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
-	dnsCache[domain] = struct {
-		records map[string][]string
-		expiry  time.Time
-	}{records: records, expiry: time.Now().Add(10 * time.Minute)}
-}
-
-// getCachedResult retrieves cached DNS records
-func getCachedResult(domain string) (map[string][]string, bool) {
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
-	entry, exists := dnsCache[domain]
-	if !exists || time.Now().After(entry.expiry) {
-		return nil, false
-	}
-	return entry.records, true
+// isNXDomainErr reports whether err is a stdlib "no such host" error.
+func isNXDomainErr(err error) bool {
+	dnsErr, ok := err.(*net.DNSError)
+	return ok && dnsErr.IsNotFound
 }
 
-// resolveDNS resolves DNS for a single domain
-func resolveDNS(domain string) map[string][]string {
-	domain = normalizeDomain(domain)
-	if cached, found := getCachedResult(domain); found {
-		fmt.Println("Cache hit!")
-		return cached
+// resolveOnce performs a single DNS lookup for an exact name - no cache,
+// hosts file or search-list expansion. When transport is nil it uses the
+// stdlib net.Lookup* functions; otherwise it sends raw DNS queries over the
+// given Transport (UDP, TCP, DoT, DoH, or a racing combination of servers).
+// dnssec requests RRSIGs via the EDNS0 DO bit and is only honored when
+// transport is non-nil. The second return value lists a human-readable
+// error per failed lookup (ending in "NXDOMAIN" when the name doesn't
+// exist, so resolveWithSearchList can move on to the next candidate), and
+// the third is the lowest TTL seen across all answers.
+func resolveOnce(domain string, transport Transport, dnssec bool) (map[string][]string, []string, time.Duration) {
+	if transport != nil {
+		records, errs, ttlSeconds := queryOverTransport(domain, transport, dnssec)
+		return records, errs, time.Duration(ttlSeconds) * time.Second
 	}
 
 	records := make(map[string][]string)
+	var errs []string
 
 	// A and AAAA Records
 	ips, err := net.LookupIP(domain)
@@ -80,6 +68,11 @@ func resolveDNS(domain string) map[string][]string {
 		}
 	} else {
 		log.Printf("Error looking up IP: %v\n", err)
+		if isNXDomainErr(err) {
+			errs = append(errs, "A/AAAA: NXDOMAIN")
+		} else {
+			errs = append(errs, fmt.Sprintf("A/AAAA: %v", err))
+		}
 	}
 
 	// CNAME Record
@@ -88,6 +81,11 @@ func resolveDNS(domain string) map[string][]string {
 		records["CNAME"] = []string{cname}
 	} else {
 		log.Printf("Error looking up CNAME: %v\n", err)
+		if isNXDomainErr(err) {
+			errs = append(errs, "CNAME: NXDOMAIN")
+		} else {
+			errs = append(errs, fmt.Sprintf("CNAME: %v", err))
+		}
 	}
 
 	// MX Records
@@ -98,6 +96,11 @@ func resolveDNS(domain string) map[string][]string {
 		}
 	} else {
 		log.Printf("Error looking up MX: %v\n", err)
+		if isNXDomainErr(err) {
+			errs = append(errs, "MX: NXDOMAIN")
+		} else {
+			errs = append(errs, fmt.Sprintf("MX: %v", err))
+		}
 	}
 
 	// TXT Records
@@ -106,6 +109,11 @@ func resolveDNS(domain string) map[string][]string {
 		records["TXT"] = txtRecords
 	} else {
 		log.Printf("Error looking up TXT: %v\n", err)
+		if isNXDomainErr(err) {
+			errs = append(errs, "TXT: NXDOMAIN")
+		} else {
+			errs = append(errs, fmt.Sprintf("TXT: %v", err))
+		}
 	}
 
 	// NS Records
@@ -116,80 +124,260 @@ func resolveDNS(domain string) map[string][]string {
 		}
 	} else {
 		log.Printf("Error looking up NS: %v\n", err)
+		if isNXDomainErr(err) {
+			errs = append(errs, "NS: NXDOMAIN")
+		} else {
+			errs = append(errs, fmt.Sprintf("NS: %v", err))
+		}
 	}
 
-	cacheResult(domain, records)
-	return records
+	return records, errs, 0
 }
 
-// reverseDNS performs reverse DNS lookup for an IP
-func reverseDNS(ip string) {
-	hosts, err := net.LookupAddr(ip)
+// resolveDNS resolves DNS for a single domain: it consults /etc/hosts (or
+// --hosts) first, then the cache, then walks the resolv.conf (or
+// --resolv-conf/--ndots) search list via resolveWithSearchList, caching
+// whatever it finds under domain so batching and repeated lookups behave
+// the same regardless of how the query was actually sent. The third return
+// value is the candidate name that actually supplied the records (which may
+// carry a search suffix domain doesn't have), for callers that need to act
+// on the resolved name rather than the original query. On a hosts-file or
+// cache hit it is domain itself, since the cache does not keep the
+// candidate name.
+func resolveDNS(domain string, transport Transport, dnssec bool) (map[string][]string, []string, string) {
+	domain = normalizeDomain(domain)
+
+	if hostRecords, ok := lookupHostsFile(domain); ok {
+		return hostRecords, nil, domain
+	}
+
+	if cached, found := getCachedResult(domain); found {
+		fmt.Println("Cache hit!")
+		return cached, nil, domain
+	}
+
+	records, errs, ttl, resolved := resolveWithSearchList(domain, searchDomains, ndotsOption, func(candidate string) (map[string][]string, []string, time.Duration) {
+		return resolveOnce(candidate, transport, dnssec)
+	})
+
+	cacheResult(domain, records, ttl)
+	return records, errs, resolved
+}
+
+// reverseDNS performs reverse DNS lookup for an IP. When transport is nil it
+// uses net.LookupAddr; otherwise it sends a raw PTR query over the Transport.
+func reverseDNS(ip string, transport Transport) {
+	if transport == nil {
+		hosts, err := net.LookupAddr(ip)
+		if err != nil {
+			log.Printf("Error during reverse DNS lookup: %v\n", err)
+			return
+		}
+		fmt.Println("Reverse DNS:")
+		for _, host := range hosts {
+			fmt.Println(" -", host)
+		}
+		return
+	}
+
+	name, err := reverseAddrName(ip)
 	if err != nil {
-		log.Printf("Error during reverse DNS lookup: %v\n", err)
+		log.Printf("Error building reverse lookup name for %s: %v\n", ip, err)
 		return
 	}
-	fmt.Println("Reverse DNS:")
-	for _, host := range hosts {
-		fmt.Println(" -", host)
+
+	query, err := buildQuery(name, dnsmessage.TypePTR, false)
+	if err != nil {
+		log.Printf("Error building PTR query for %s: %v\n", ip, err)
+		return
 	}
-}
 
-// resolveBatch reads domains from a file and resolves them
-func resolveBatch(filePath string) {
-	file, err := os.Open(filePath)
+	resp, err := transport.RoundTrip(context.Background(), &query)
 	if err != nil {
-		log.Fatalf("Error reading file: %v\n", err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	var wg sync.WaitGroup
-	for scanner.Scan() {
-		domain := scanner.Text()
-		wg.Add(1)
-		go func(domain string) {
-			defer wg.Done()
-			fmt.Printf("\nResolving: %s\n", domain)
-			records := resolveDNS(domain)
-			printRecords(domain, records)
-		}(domain)
-	}
-	wg.Wait()
+		log.Printf("Error during reverse DNS lookup for %s: %v\n", ip, err)
+		return
+	}
+	if err := validateResponse(&query, resp); err != nil {
+		log.Printf("Invalid reverse DNS response for %s: %v\n", ip, err)
+		return
+	}
+
+	fmt.Println("Reverse DNS:")
+	for _, answer := range resp.Answers {
+		if ptr, ok := answer.Body.(*dnsmessage.PTRResource); ok {
+			fmt.Println(" -", strings.TrimSuffix(ptr.PTR.String(), "."))
+		}
+	}
 }
 
-// printRecords formats and prints DNS records
-func printRecords(domain string, records map[string][]string) {
+// negativeDNSSECKey is the dnssecStatus map key used for a negative
+// (NXDOMAIN/NODATA) answer, which has no record type of its own to key off.
+const negativeDNSSECKey = "NXDOMAIN"
+
+// printRecords formats and prints DNS records. dnssecStatus, if non-nil, maps
+// a record type ("A", "MX", ...) to its DNSSEC validation status and is
+// appended to that record type's header line; negativeDNSSECKey carries the
+// status of the NSEC/NSEC3 non-existence proof when there are no records.
+func printRecords(domain string, records map[string][]string, dnssecStatus map[string]string) {
 	fmt.Printf("\nDNS Records for %s:\n", domain)
+	if len(records) == 0 {
+		if status, ok := dnssecStatus[negativeDNSSECKey]; ok {
+			fmt.Printf("No records found. [DNSSEC: %s]\n", status)
+		}
+		return
+	}
 	for recordType, values := range records {
-		fmt.Printf("%s Records:\n", recordType)
+		if status, ok := dnssecStatus[recordType]; ok {
+			fmt.Printf("%s Records: [DNSSEC: %s]\n", recordType, status)
+		} else {
+			fmt.Printf("%s Records:\n", recordType)
+		}
 		for _, value := range values {
 			fmt.Println(" -", value)
 		}
 	}
 }
 
+// hasFlag reports whether flag appears verbatim among args.
+func hasFlag(args []string, flag string) bool {
+	for _, a := range args {
+		if a == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// flagValue returns the argument following flag, if present.
+func flagValue(args []string, flag string) (string, bool) {
+	for i, a := range args {
+		if a == flag && i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+// resolveMode selects which action main takes for arg. --file is checked
+// first: a batch file path (e.g. "domains.txt", "/data/domains.txt") is
+// overwhelmingly likely to contain a ".", so checking modeDomain first would
+// swallow the documented "<path> --file" usage into a single bogus lookup.
+type mode int
+
+const (
+	modeInvalid mode = iota
+	modeBatch
+	modeDomain
+	modeReverse
+)
+
+func resolveMode(arg string, flagArgs []string) mode {
+	switch {
+	case len(flagArgs) >= 1 && flagArgs[0] == "--file":
+		return modeBatch
+	case strings.Contains(arg, "."):
+		return modeDomain
+	case strings.Contains(arg, ":"):
+		return modeReverse
+	default:
+		return modeInvalid
+	}
+}
+
 func main() {
 	initLogger()
 
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run main.go <domain|ip> [--reverse|--file <path>|--server <dns_server>]")
+		fmt.Println("Usage: go run main.go <domain|ip|path> [--reverse|--file [--concurrency N] [--qps N] [--cache-size N]|--server <dns_server>[,<dns_server>...] [--tcp]|--dot <host>|--doh <url>|--dnssec|--format {text,json,dig,jsonl}|--hosts <path>|--resolv-conf <path>|--ndots N]")
 		return
 	}
 
 	arg := os.Args[1]
-	switch {
-	case strings.Contains(arg, "."):
-		if len(os.Args) == 4 && os.Args[2] == "--server" {
-			fmt.Println("Coming soon ...")
-		} else {
-			records := resolveDNS(arg)
-			printRecords(arg, records)
+	flagArgs := os.Args[2:]
+	transport := buildTransportFromArgs(flagArgs)
+
+	hostsPath := "/etc/hosts"
+	if p, ok := flagValue(flagArgs, "--hosts"); ok {
+		hostsPath = p
+	}
+	if entries, err := loadHostsFile(hostsPath); err == nil {
+		hostsEntries = entries
+	} else {
+		log.Printf("Not using hosts file %s: %v\n", hostsPath, err)
+	}
+
+	resolvConfPath := "/etc/resolv.conf"
+	if p, ok := flagValue(flagArgs, "--resolv-conf"); ok {
+		resolvConfPath = p
+	}
+	if searches, ndots, err := loadResolvConf(resolvConfPath); err == nil {
+		searchDomains = searches
+		ndotsOption = ndots
+	} else {
+		log.Printf("Not using resolv.conf %s: %v\n", resolvConfPath, err)
+	}
+	if ndotsStr, ok := flagValue(flagArgs, "--ndots"); ok {
+		if n, err := strconv.Atoi(ndotsStr); err == nil {
+			ndotsOption = n
 		}
-	case strings.Contains(arg, ":"):
-		reverseDNS(arg)
-	case len(os.Args) == 3 && os.Args[2] == "--file":
-		resolveBatch(arg)
+	}
+	dnssec := hasFlag(flagArgs, "--dnssec")
+	if dnssec && transport == nil {
+		transport = newDualTransport(defaultDNSSECResolver)
+	}
+
+	format := FormatText
+	if formatStr, ok := flagValue(flagArgs, "--format"); ok {
+		parsed, err := parseFormat(formatStr)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		format = parsed
+	}
+	if format == FormatDig && transport == nil {
+		transport = newDualTransport(defaultDNSSECResolver)
+	}
+
+	if sizeStr, ok := flagValue(flagArgs, "--cache-size"); ok {
+		if size, err := strconv.Atoi(sizeStr); err == nil {
+			defaultCache = newLRUCache(size)
+		}
+	}
+
+	concurrency := 0
+	if concurrencyStr, ok := flagValue(flagArgs, "--concurrency"); ok {
+		if n, err := strconv.Atoi(concurrencyStr); err == nil {
+			concurrency = n
+		}
+	}
+
+	qps := float64(defaultBatchQPS)
+	if qpsStr, ok := flagValue(flagArgs, "--qps"); ok {
+		if n, err := strconv.ParseFloat(qpsStr, 64); err == nil {
+			qps = n
+		}
+	}
+
+	switch resolveMode(arg, flagArgs) {
+	case modeBatch:
+		resolveBatch(arg, transport, format, concurrency, qps)
+	case modeDomain:
+		records, errs, resolved := resolveDNS(arg, transport, dnssec)
+
+		var status map[string]string
+		if dnssec {
+			status = make(map[string]string, len(records))
+			for recordType := range records {
+				status[recordType] = dnssecStatusForType(resolved, queryTypeForRecordType(recordType), transport)
+			}
+			if len(records) == 0 && transport != nil {
+				status[negativeDNSSECKey] = dnssecStatusForNegative(resolved, transport)
+			}
+		}
+		printFormatted(arg, records, errs, status, format, transport)
+	case modeReverse:
+		reverseDNS(arg, transport)
 	default:
 		fmt.Println("Invalid input.")
 	}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+// TestResolveModeBatchBeatsRealisticPaths pins the --file dispatch against
+// realistic batch file paths (which overwhelmingly contain a "."), so the
+// dot-contains domain case can't silently shadow it again.
+func TestResolveModeBatchBeatsRealisticPaths(t *testing.T) {
+	paths := []string{"domains.txt", "./hosts", "/data/domains.txt", "/tmp/domains.txt"}
+	for _, path := range paths {
+		if got := resolveMode(path, []string{"--file"}); got != modeBatch {
+			t.Errorf("resolveMode(%q, [--file]) = %v, want modeBatch", path, got)
+		}
+	}
+}
+
+func TestResolveMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		arg      string
+		flagArgs []string
+		want     mode
+	}{
+		{"file flag wins over dotted path", "/tmp/domains.txt", []string{"--file"}, modeBatch},
+		{"file flag must be first flag", "example.com", []string{"--dnssec", "--file"}, modeDomain},
+		{"dotted domain", "example.com", nil, modeDomain},
+		{"bare domain with other flags", "example.com", []string{"--dnssec"}, modeDomain},
+		{"colon-only address is reverse", "2001:db8::1", nil, modeReverse},
+		{"no dot, no colon, no file flag", "localhost", nil, modeInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveMode(tt.arg, tt.flagArgs); got != tt.want {
+				t.Errorf("resolveMode(%q, %v) = %v, want %v", tt.arg, tt.flagArgs, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// OutputFormat selects how resolved records are rendered.
+type OutputFormat string
+
+const (
+	FormatText  OutputFormat = "text"
+	FormatJSON  OutputFormat = "json"
+	FormatDig   OutputFormat = "dig"
+	FormatJSONL OutputFormat = "jsonl"
+)
+
+// parseFormat validates a --format value.
+func parseFormat(s string) (OutputFormat, error) {
+	switch OutputFormat(s) {
+	case FormatText, FormatJSON, FormatDig, FormatJSONL:
+		return OutputFormat(s), nil
+	default:
+		return "", fmt.Errorf("unknown format %q (want text, json, dig or jsonl)", s)
+	}
+}
+
+// jsonMXRecord is the structured MX entry shape used by JSON output.
+type jsonMXRecord struct {
+	Host     string `json:"host"`
+	Priority int    `json:"priority"`
+}
+
+// jsonResult is the stable schema emitted by the json and jsonl formats.
+type jsonResult struct {
+	Domain    string                 `json:"domain"`
+	QueriedAt string                 `json:"queried_at"`
+	Records   map[string]interface{} `json:"records"`
+	Errors    []string               `json:"errors"`
+}
+
+// parseMXValue splits the "host (Priority: N)" strings stored in the
+// records map back into their structured host/priority fields.
+func parseMXValue(value string) (host string, priority int, ok bool) {
+	const marker = " (Priority: "
+	idx := strings.Index(value, marker)
+	if idx == -1 || !strings.HasSuffix(value, ")") {
+		return "", 0, false
+	}
+	p, err := strconv.Atoi(value[idx+len(marker) : len(value)-1])
+	if err != nil {
+		return "", 0, false
+	}
+	return value[:idx], p, true
+}
+
+// buildJSONRecords converts the flat records map into the JSON schema's
+// shape, expanding MX entries into {"host", "priority"} objects.
+func buildJSONRecords(records map[string][]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(records))
+	for recordType, values := range records {
+		if recordType != "MX" {
+			out[recordType] = values
+			continue
+		}
+		mxEntries := make([]jsonMXRecord, 0, len(values))
+		for _, v := range values {
+			if host, priority, ok := parseMXValue(v); ok {
+				mxEntries = append(mxEntries, jsonMXRecord{Host: host, Priority: priority})
+			}
+		}
+		out["MX"] = mxEntries
+	}
+	return out
+}
+
+// buildJSONResult assembles the stable per-domain JSON schema.
+func buildJSONResult(domain string, records map[string][]string, errs []string) jsonResult {
+	return jsonResult{
+		Domain:    domain,
+		QueriedAt: time.Now().UTC().Format(time.RFC3339),
+		Records:   buildJSONRecords(records),
+		Errors:    errs,
+	}
+}
+
+// renderJSON marshals a result, indented for the json format and compact
+// (single line) for jsonl so batch mode can stream it.
+func renderJSON(result jsonResult, format OutputFormat) (string, error) {
+	if format == FormatJSONL {
+		b, err := json.Marshal(result)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	b, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// digFlags renders the header flag abbreviations dig prints, e.g. "qr rd ra".
+func digFlags(h dnsmessage.Header) string {
+	var flags []string
+	if h.Response {
+		flags = append(flags, "qr")
+	}
+	if h.Authoritative {
+		flags = append(flags, "aa")
+	}
+	if h.Truncated {
+		flags = append(flags, "tc")
+	}
+	if h.RecursionDesired {
+		flags = append(flags, "rd")
+	}
+	if h.RecursionAvailable {
+		flags = append(flags, "ra")
+	}
+	if h.AuthenticData {
+		flags = append(flags, "ad")
+	}
+	return strings.Join(flags, " ")
+}
+
+// digRDATA formats an answer's RDATA the way dig would: A/AAAA as an IP
+// literal, CNAME/NS as a plain domain name and MX as "preference host",
+// falling back to the canonical wire-format bytes in hex for any other
+// resource type (TXT included).
+func digRDATA(answer dnsmessage.Resource) string {
+	switch body := answer.Body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(body.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return net.IP(body.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		return strings.TrimSuffix(body.CNAME.String(), ".")
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", body.Pref, strings.TrimSuffix(body.MX.String(), "."))
+	case *dnsmessage.NSResource:
+		return strings.TrimSuffix(body.NS.String(), ".")
+	default:
+		rdata, err := canonicalRDATA(answer.Body)
+		if err != nil {
+			return fmt.Sprintf("<unprintable: %v>", err)
+		}
+		return fmt.Sprintf("%x", rdata)
+	}
+}
+
+// renderDig renders dig-style output: header flags, question section, and
+// the answer section with real TTLs for each queried record type. It
+// requires a raw Transport, since net.Lookup* discards TTLs.
+func renderDig(domain string, transport Transport) (string, error) {
+	if transport == nil {
+		return "", fmt.Errorf("dig-style output requires a raw transport (--server, --dot or --doh)")
+	}
+
+	var buf strings.Builder
+	for _, qtype := range queryTypes {
+		query, err := buildQuery(domain, qtype, false)
+		if err != nil {
+			continue
+		}
+		resp, err := transport.RoundTrip(context.Background(), &query)
+		if err != nil {
+			fmt.Fprintf(&buf, ";; %s query failed: %v\n\n", qtype, err)
+			continue
+		}
+
+		fmt.Fprintf(&buf, ";; ->>HEADER<<- opcode: QUERY, status: %s, id: %d\n", resp.RCode, resp.ID)
+		fmt.Fprintf(&buf, ";; flags: %s; QUERY: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d\n\n",
+			digFlags(resp.Header), len(resp.Questions), len(resp.Answers), len(resp.Authorities), len(resp.Additionals))
+
+		buf.WriteString(";; QUESTION SECTION:\n")
+		fmt.Fprintf(&buf, ";%s.\t\tIN\t%s\n\n", domain, qtype)
+
+		if len(resp.Answers) > 0 {
+			buf.WriteString(";; ANSWER SECTION:\n")
+			for _, answer := range resp.Answers {
+				fmt.Fprintf(&buf, "%s.\t%d\tIN\t%s\t%s\n", domain, answer.Header.TTL, answer.Header.Type, digRDATA(answer))
+			}
+			buf.WriteString("\n")
+		}
+	}
+	return buf.String(), nil
+}
+
+// stdoutMutex serializes writes for formats where a torn line would break a
+// downstream line-oriented consumer (json/jsonl in batch mode).
+var stdoutMutex sync.Mutex
+
+// printFormatted renders records in the requested format and writes it to
+// stdout. dnssecStatus is printed by the text format only; the json, jsonl
+// and dig formats ignore it.
+func printFormatted(domain string, records map[string][]string, errs []string, dnssecStatus map[string]string, format OutputFormat, transport Transport) {
+	switch format {
+	case FormatJSON, FormatJSONL:
+		result := buildJSONResult(domain, records, errs)
+		out, err := renderJSON(result, format)
+		if err != nil {
+			log.Printf("Error rendering JSON for %s: %v\n", domain, err)
+			return
+		}
+		stdoutMutex.Lock()
+		fmt.Println(out)
+		stdoutMutex.Unlock()
+	case FormatDig:
+		out, err := renderDig(domain, transport)
+		if err != nil {
+			log.Printf("Error rendering dig-style output for %s: %v\n", domain, err)
+			return
+		}
+		stdoutMutex.Lock()
+		fmt.Print(out)
+		stdoutMutex.Unlock()
+	default:
+		printRecords(domain, records, dnssecStatus)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestParseMXValue(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		wantHost     string
+		wantPriority int
+		wantOK       bool
+	}{
+		{"well formed", "mail.example.com (Priority: 10)", "mail.example.com", 10, true},
+		{"zero priority", "mail.example.com (Priority: 0)", "mail.example.com", 0, true},
+		{"missing marker", "mail.example.com", "", 0, false},
+		{"missing closing paren", "mail.example.com (Priority: 10", "", 0, false},
+		{"non-numeric priority", "mail.example.com (Priority: abc)", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, priority, ok := parseMXValue(tt.value)
+			if ok != tt.wantOK {
+				t.Fatalf("parseMXValue(%q) ok = %v, want %v", tt.value, ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if host != tt.wantHost || priority != tt.wantPriority {
+				t.Errorf("parseMXValue(%q) = (%q, %d), want (%q, %d)", tt.value, host, priority, tt.wantHost, tt.wantPriority)
+			}
+		})
+	}
+}
+
+func TestParseMXValueRoundTripsRecordsFromResponse(t *testing.T) {
+	// recordsFromResponse formats MX entries as "host (Priority: N)";
+	// parseMXValue must be able to invert that exact shape.
+	owner := mustName(t, "example.com.")
+	resp := &dnsmessage.Message{
+		Answers: []dnsmessage.Resource{
+			{
+				Header: dnsmessage.ResourceHeader{Name: owner, Type: dnsmessage.TypeMX, Class: dnsmessage.ClassINET},
+				Body:   &dnsmessage.MXResource{Pref: 5, MX: mustName(t, "mail.example.com.")},
+			},
+		},
+	}
+	records := recordsFromResponse(resp)
+	host, priority, ok := parseMXValue(records["MX"][0])
+	if !ok {
+		t.Fatalf("parseMXValue(%q) ok = false, want true", records["MX"][0])
+	}
+	if host != "mail.example.com" || priority != 5 {
+		t.Errorf("parseMXValue(%q) = (%q, %d), want (\"mail.example.com\", 5)", records["MX"][0], host, priority)
+	}
+}
+
+func TestBuildJSONRecords(t *testing.T) {
+	records := map[string][]string{
+		"A":  {"93.184.216.34"},
+		"MX": {"mail.example.com (Priority: 10)", "not-a-valid-mx-entry"},
+	}
+	got := buildJSONRecords(records)
+
+	if !reflect.DeepEqual(got["A"], []string{"93.184.216.34"}) {
+		t.Errorf("buildJSONRecords A = %v, want [93.184.216.34]", got["A"])
+	}
+
+	mx, ok := got["MX"].([]jsonMXRecord)
+	if !ok {
+		t.Fatalf("buildJSONRecords MX = %T, want []jsonMXRecord", got["MX"])
+	}
+	want := []jsonMXRecord{{Host: "mail.example.com", Priority: 10}}
+	if !reflect.DeepEqual(mx, want) {
+		t.Errorf("buildJSONRecords MX = %+v, want %+v (malformed entries dropped)", mx, want)
+	}
+}
+
+func TestDigRDATA(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer dnsmessage.Resource
+		want   string
+	}{
+		{
+			name: "A record",
+			answer: dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeA},
+				Body:   &dnsmessage.AResource{A: [4]byte(net.ParseIP("93.184.216.34").To4())},
+			},
+			want: "93.184.216.34",
+		},
+		{
+			name: "CNAME record",
+			answer: dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeCNAME},
+				Body:   &dnsmessage.CNAMEResource{CNAME: mustName(t, "alias.example.com.")},
+			},
+			want: "alias.example.com",
+		},
+		{
+			name: "MX record",
+			answer: dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeMX},
+				Body:   &dnsmessage.MXResource{Pref: 10, MX: mustName(t, "mail.example.com.")},
+			},
+			want: "10 mail.example.com",
+		},
+		{
+			name: "NS record",
+			answer: dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeNS},
+				Body:   &dnsmessage.NSResource{NS: mustName(t, "ns1.example.com.")},
+			},
+			want: "ns1.example.com",
+		},
+		{
+			name: "unknown type falls back to hex",
+			answer: dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Type: typeDS},
+				Body:   &dnsmessage.UnknownResource{Data: []byte{0xde, 0xad, 0xbe, 0xef}},
+			},
+			want: "deadbeef",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := digRDATA(tt.answer); got != tt.want {
+				t.Errorf("digRDATA(%s) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultNdots matches glibc's resolv.conf default when "options ndots:N" is
+// absent: a name needs at least this many dots to be tried absolute before
+// any search suffix.
+const defaultNdots = 1
+
+// hostsEntries, searchDomains and ndotsOption are populated once in main()
+// from /etc/hosts and /etc/resolv.conf (or their --hosts/--resolv-conf/
+// --ndots overrides) before any lookups happen.
+var (
+	hostsEntries  map[string][]string
+	searchDomains []string
+	ndotsOption   = defaultNdots
+)
+
+// loadHostsFile parses a hosts(5)-style file into a lowercase hostname ->
+// IP-literal-strings map, mirroring /etc/hosts's "ip name [alias...]" format.
+func loadHostsFile(path string) (map[string][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make(map[string][]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx != -1 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := fields[0]
+		for _, name := range fields[1:] {
+			name = strings.ToLower(strings.TrimSuffix(name, "."))
+			entries[name] = append(entries[name], ip)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// lookupHostsFile returns the A/AAAA records for domain from hostsEntries,
+// if any were loaded and domain has an entry.
+func lookupHostsFile(domain string) (map[string][]string, bool) {
+	if hostsEntries == nil {
+		return nil, false
+	}
+	ips, ok := hostsEntries[strings.ToLower(domain)]
+	if !ok {
+		return nil, false
+	}
+
+	records := make(map[string][]string)
+	for _, ipStr := range ips {
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			continue
+		}
+		if ip.To4() != nil {
+			records["A"] = append(records["A"], ip.String())
+		} else {
+			records["AAAA"] = append(records["AAAA"], ip.String())
+		}
+	}
+	if len(records) == 0 {
+		return nil, false
+	}
+	return records, true
+}
+
+// loadResolvConf parses the "search"/"domain" and "options ndots:N" lines
+// out of a resolv.conf(5)-style file; everything else (nameserver, sortlist,
+// ...) is left to --server/--dot/--doh.
+func loadResolvConf(path string) ([]string, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, defaultNdots, err
+	}
+	defer file.Close()
+
+	var searches []string
+	ndots := defaultNdots
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+		switch fields[0] {
+		case "search", "domain":
+			searches = fields[1:]
+		case "options":
+			for _, opt := range fields[1:] {
+				if n, ok := strings.CutPrefix(opt, "ndots:"); ok {
+					if v, err := strconv.Atoi(n); err == nil {
+						ndots = v
+					}
+				}
+			}
+		}
+	}
+	return searches, ndots, scanner.Err()
+}
+
+// applySearchList produces the ordered candidate FQDNs for name given the
+// resolv.conf search list and ndots option (RFC 3484 / glibc ndots
+// semantics): a name already ending in "." is absolute and used as-is; a
+// name with at least ndots dots is tried on its own first, then with each
+// search suffix appended; otherwise the search suffixes are tried first and
+// the bare name last.
+func applySearchList(name string, searches []string, ndots int) []string {
+	if strings.HasSuffix(name, ".") {
+		return []string{strings.TrimSuffix(name, ".")}
+	}
+
+	hasNdots := strings.Count(name, ".") >= ndots
+	candidates := make([]string, 0, 1+len(searches))
+	if hasNdots {
+		candidates = append(candidates, name)
+	}
+	for _, suffix := range searches {
+		suffix = strings.TrimSuffix(suffix, ".")
+		if suffix == "" {
+			continue
+		}
+		candidates = append(candidates, name+"."+suffix)
+	}
+	if !hasNdots {
+		candidates = append(candidates, name)
+	}
+	return candidates
+}
+
+// allNXDOMAIN reports whether every error in errs is the NXDOMAIN marker
+// appended by resolveOnce, i.e. the candidate name simply doesn't exist
+// rather than having failed for some other reason.
+func allNXDOMAIN(errs []string) bool {
+	if len(errs) == 0 {
+		return false
+	}
+	for _, e := range errs {
+		if !strings.HasSuffix(e, "NXDOMAIN") {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveWithSearchList tries each search-list candidate for name in order
+// via lookup, skipping any that comes back NXDOMAIN, and stops at the first
+// candidate that returns a usable result - matching real resolver behaviour
+// (glibc, Go's stdlib resolver) rather than merging records from multiple,
+// unrelated search-suffixed hosts into one answer. The returned string is
+// the candidate name that actually supplied the records, so callers that
+// need to act on the resolved name (e.g. DNSSEC validation) don't validate
+// against the wrong zone.
+func resolveWithSearchList(name string, searches []string, ndots int, lookup func(string) (map[string][]string, []string, time.Duration)) (map[string][]string, []string, time.Duration, string) {
+	candidates := applySearchList(name, searches, ndots)
+
+	var lastErrs []string
+	for _, candidate := range candidates {
+		candRecords, candErrs, candTTL := lookup(candidate)
+		if len(candRecords) == 0 && allNXDOMAIN(candErrs) {
+			lastErrs = candErrs
+			continue
+		}
+
+		return candRecords, candErrs, candTTL, candidate
+	}
+
+	return make(map[string][]string, 0), lastErrs, 0, name
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApplySearchList(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     string
+		searches []string
+		ndots    int
+		want     []string
+	}{
+		{
+			name:     "absolute name is used as-is",
+			host:     "example.com.",
+			searches: []string{"corp.example"},
+			ndots:    1,
+			want:     []string{"example.com"},
+		},
+		{
+			name:     "meets ndots tries bare name first",
+			host:     "www.example.com",
+			searches: []string{"corp.example"},
+			ndots:    1,
+			want:     []string{"www.example.com", "www.example.com.corp.example"},
+		},
+		{
+			name:     "below ndots tries search suffixes first",
+			host:     "host",
+			searches: []string{"corp.example", "eng.example"},
+			ndots:    1,
+			want:     []string{"host.corp.example", "host.eng.example", "host"},
+		},
+		{
+			name:     "blank search entries are skipped",
+			host:     "host",
+			searches: []string{"", "corp.example"},
+			ndots:    1,
+			want:     []string{"host.corp.example", "host"},
+		},
+		{
+			name:     "no search list below ndots returns just the bare name",
+			host:     "host",
+			searches: nil,
+			ndots:    1,
+			want:     []string{"host"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := applySearchList(tt.host, tt.searches, tt.ndots)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applySearchList(%q, %v, %d) = %v, want %v", tt.host, tt.searches, tt.ndots, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllNXDOMAIN(t *testing.T) {
+	tests := []struct {
+		name string
+		errs []string
+		want bool
+	}{
+		{"empty errs is not all-NXDOMAIN", nil, false},
+		{"single NXDOMAIN", []string{"A: NXDOMAIN"}, true},
+		{"mixed NXDOMAIN and other error", []string{"A: NXDOMAIN", "AAAA: timeout"}, false},
+		{"all NXDOMAIN across types", []string{"A: NXDOMAIN", "AAAA: NXDOMAIN"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allNXDOMAIN(tt.errs); got != tt.want {
+				t.Errorf("allNXDOMAIN(%v) = %v, want %v", tt.errs, got, tt.want)
+			}
+		})
+	}
+}
+
+func writeTempFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "testfile")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("writing temp file: %v", err)
+	}
+	return path
+}
+
+func TestLoadHostsFile(t *testing.T) {
+	path := writeTempFile(t, "127.0.0.1 localhost\n"+
+		"192.0.2.1 router.lan router\n"+
+		"# comment line\n"+
+		"::1 ip6-localhost\n"+
+		"\n")
+
+	entries, err := loadHostsFile(path)
+	if err != nil {
+		t.Fatalf("loadHostsFile returned error: %v", err)
+	}
+
+	want := map[string][]string{
+		"localhost":     {"127.0.0.1"},
+		"router.lan":    {"192.0.2.1"},
+		"router":        {"192.0.2.1"},
+		"ip6-localhost": {"::1"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("loadHostsFile = %v, want %v", entries, want)
+	}
+}
+
+func TestLoadHostsFileMissing(t *testing.T) {
+	if _, err := loadHostsFile(filepath.Join(t.TempDir(), "does-not-exist")); err == nil {
+		t.Error("loadHostsFile on a missing file returned no error, want one")
+	}
+}
+
+func TestLookupHostsFile(t *testing.T) {
+	orig := hostsEntries
+	defer func() { hostsEntries = orig }()
+
+	hostsEntries = map[string][]string{
+		"router.lan": {"192.0.2.1", "::1"},
+	}
+
+	records, ok := lookupHostsFile("Router.LAN")
+	if !ok {
+		t.Fatal("lookupHostsFile returned ok = false for a known (differently-cased) host")
+	}
+	if records["A"][0] != "192.0.2.1" || records["AAAA"][0] != "::1" {
+		t.Errorf("lookupHostsFile = %v, want A=192.0.2.1 AAAA=::1", records)
+	}
+
+	if _, ok := lookupHostsFile("unknown.lan"); ok {
+		t.Error("lookupHostsFile returned ok = true for an unknown host")
+	}
+
+	hostsEntries = nil
+	if _, ok := lookupHostsFile("router.lan"); ok {
+		t.Error("lookupHostsFile returned ok = true with hostsEntries unset")
+	}
+}
+
+func TestLoadResolvConf(t *testing.T) {
+	path := writeTempFile(t, "nameserver 8.8.8.8\n"+
+		"search corp.example eng.example\n"+
+		"options ndots:2 timeout:1\n"+
+		"; comment line\n")
+
+	searches, ndots, err := loadResolvConf(path)
+	if err != nil {
+		t.Fatalf("loadResolvConf returned error: %v", err)
+	}
+	if !reflect.DeepEqual(searches, []string{"corp.example", "eng.example"}) {
+		t.Errorf("loadResolvConf searches = %v, want [corp.example eng.example]", searches)
+	}
+	if ndots != 2 {
+		t.Errorf("loadResolvConf ndots = %d, want 2", ndots)
+	}
+}
+
+func TestLoadResolvConfDomainFallsBackToDefaultNdots(t *testing.T) {
+	path := writeTempFile(t, "domain example.com\n")
+
+	searches, ndots, err := loadResolvConf(path)
+	if err != nil {
+		t.Fatalf("loadResolvConf returned error: %v", err)
+	}
+	if !reflect.DeepEqual(searches, []string{"example.com"}) {
+		t.Errorf("loadResolvConf searches = %v, want [example.com]", searches)
+	}
+	if ndots != defaultNdots {
+		t.Errorf("loadResolvConf ndots = %d, want default %d", ndots, defaultNdots)
+	}
+}
+
+func TestLoadResolvConfMissing(t *testing.T) {
+	if _, _, err := loadResolvConf(filepath.Join(t.TempDir(), "does-not-exist")); err == nil {
+		t.Error("loadResolvConf on a missing file returned no error, want one")
+	}
+}
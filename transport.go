@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	dnsQueryTimeout = 2 * time.Second
+	dnsMaxRetries   = 2
+)
+
+// Transport sends a DNS query message and returns the response, so that
+// queryOverTransport can work over UDP, TCP, DoT or DoH interchangeably.
+type Transport interface {
+	RoundTrip(ctx context.Context, query *dnsmessage.Message) (*dnsmessage.Message, error)
+}
+
+func dialContext(ctx context.Context, network, addr string, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+func connDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	if deadline, ok := ctx.Deadline(); ok {
+		return deadline
+	}
+	return time.Now().Add(timeout)
+}
+
+// splitHostPort returns server's dial address, appending defaultPort when
+// server is a bare host (no "host:port" given) rather than assuming one.
+func splitHostPort(server, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(server, defaultPort)
+}
+
+// UDPTransport sends queries over plain UDP to a single server.
+type UDPTransport struct {
+	Server  string
+	Timeout time.Duration
+}
+
+func (t *UDPTransport) timeout() time.Duration {
+	if t.Timeout > 0 {
+		return t.Timeout
+	}
+	return dnsQueryTimeout
+}
+
+func (t *UDPTransport) RoundTrip(ctx context.Context, query *dnsmessage.Message) (*dnsmessage.Message, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("packing query: %w", err)
+	}
+
+	addr := splitHostPort(t.Server, "53")
+	conn, err := dialContext(ctx, "udp", addr, t.timeout())
+	if err != nil {
+		return nil, fmt.Errorf("dialing %s: %w", addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(connDeadline(ctx, t.timeout()))
+
+	if _, err := conn.Write(packed); err != nil {
+		return nil, fmt.Errorf("writing query to %s: %w", addr, err)
+	}
+
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("reading response from %s: %w", addr, err)
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf[:n]); err != nil {
+		return nil, fmt.Errorf("unpacking response from %s: %w", addr, err)
+	}
+	return &resp, nil
+}
+
+// TCPTransport sends queries over TCP using the 2-byte length prefix framing
+// shared by plain TCP and DNS-over-TLS.
+type TCPTransport struct {
+	Server  string
+	Timeout time.Duration
+}
+
+func (t *TCPTransport) timeout() time.Duration {
+	if t.Timeout > 0 {
+		return t.Timeout
+	}
+	return dnsQueryTimeout
+}
+
+func (t *TCPTransport) RoundTrip(ctx context.Context, query *dnsmessage.Message) (*dnsmessage.Message, error) {
+	addr := splitHostPort(t.Server, "53")
+	conn, err := dialContext(ctx, "tcp", addr, t.timeout())
+	if err != nil {
+		return nil, fmt.Errorf("dialing %s: %w", addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(connDeadline(ctx, t.timeout()))
+	return exchangeFramed(conn, query, addr)
+}
+
+// exchangeFramed sends a query and reads a response over a stream conn using
+// the 2-byte length prefix framing used by DNS-over-TCP and DNS-over-TLS.
+func exchangeFramed(conn net.Conn, query *dnsmessage.Message, server string) (*dnsmessage.Message, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("packing query: %w", err)
+	}
+
+	length := len(packed)
+	framed := append([]byte{byte(length >> 8), byte(length)}, packed...)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, fmt.Errorf("writing query to %s: %w", server, err)
+	}
+
+	lenBuf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, lenBuf); err != nil {
+		return nil, fmt.Errorf("reading response length from %s: %w", server, err)
+	}
+	respLen := int(lenBuf[0])<<8 | int(lenBuf[1])
+
+	respBuf := make([]byte, respLen)
+	if _, err := io.ReadFull(conn, respBuf); err != nil {
+		return nil, fmt.Errorf("reading response from %s: %w", server, err)
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(respBuf); err != nil {
+		return nil, fmt.Errorf("unpacking response from %s: %w", server, err)
+	}
+	return &resp, nil
+}
+
+// dualTransport tries UDP first, retransmitting on timeout, and falls back
+// to TCP when the UDP response is truncated.
+type dualTransport struct {
+	udp *UDPTransport
+	tcp *TCPTransport
+}
+
+func newDualTransport(server string) *dualTransport {
+	return &dualTransport{
+		udp: &UDPTransport{Server: server},
+		tcp: &TCPTransport{Server: server},
+	}
+}
+
+func (t *dualTransport) RoundTrip(ctx context.Context, query *dnsmessage.Message) (*dnsmessage.Message, error) {
+	var lastErr error
+	for attempt := 0; attempt <= dnsMaxRetries; attempt++ {
+		resp, err := t.udp.RoundTrip(ctx, query)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.Header.Truncated {
+			return t.tcp.RoundTrip(ctx, query)
+		}
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+// DoTTransport sends queries over DNS-over-TLS (RFC 7858): TLS on port 853
+// using the same 2-byte length prefix framing as DNS-over-TCP.
+type DoTTransport struct {
+	Server  string // host or host:port; defaults to port 853
+	Timeout time.Duration
+}
+
+func (t *DoTTransport) timeout() time.Duration {
+	if t.Timeout > 0 {
+		return t.Timeout
+	}
+	return dnsQueryTimeout
+}
+
+func (t *DoTTransport) hostPort() (host, addr string) {
+	host = t.Server
+	if h, _, err := net.SplitHostPort(t.Server); err == nil {
+		host = h
+	}
+	return host, splitHostPort(t.Server, "853")
+}
+
+func (t *DoTTransport) RoundTrip(ctx context.Context, query *dnsmessage.Message) (*dnsmessage.Message, error) {
+	host, addr := t.hostPort()
+
+	rawConn, err := dialContext(ctx, "tcp", addr, t.timeout())
+	if err != nil {
+		return nil, fmt.Errorf("dialing %s: %w", addr, err)
+	}
+	defer rawConn.Close()
+	rawConn.SetDeadline(connDeadline(ctx, t.timeout()))
+
+	tlsConn := tls.Client(rawConn, &tls.Config{ServerName: host})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("TLS handshake with %s: %w", addr, err)
+	}
+
+	return exchangeFramed(tlsConn, query, addr)
+}
+
+// DoHTransport sends queries over DNS-over-HTTPS (RFC 8484) by POSTing the
+// wire-format message to a configurable URL. The shared http.Client pools
+// and keeps connections alive across repeated lookups (batch mode benefits).
+type DoHTransport struct {
+	URL    string
+	Client *http.Client
+}
+
+func newDoHTransport(url string) *DoHTransport {
+	return &DoHTransport{
+		URL: url,
+		Client: &http.Client{
+			Timeout: dnsQueryTimeout,
+			Transport: &http.Transport{
+				MaxIdleConns:        100,
+				MaxIdleConnsPerHost: 10,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+	}
+}
+
+func (t *DoHTransport) RoundTrip(ctx context.Context, query *dnsmessage.Message) (*dnsmessage.Message, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("packing query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(packed))
+	if err != nil {
+		return nil, fmt.Errorf("building DoH request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	res, err := t.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("DoH request to %s: %w", t.URL, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH request to %s returned status %d", t.URL, res.StatusCode)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading DoH response from %s: %w", t.URL, err)
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(body); err != nil {
+		return nil, fmt.Errorf("unpacking DoH response from %s: %w", t.URL, err)
+	}
+	return &resp, nil
+}
+
+// multiTransport races the same query against every underlying transport in
+// parallel and returns the first successful, validated response, which is
+// how newServerTransport fails over between multiple --server hosts.
+type multiTransport struct {
+	transports []Transport
+}
+
+func (t *multiTransport) RoundTrip(ctx context.Context, query *dnsmessage.Message) (*dnsmessage.Message, error) {
+	type result struct {
+		resp *dnsmessage.Message
+		err  error
+	}
+
+	results := make(chan result, len(t.transports))
+	for _, transport := range t.transports {
+		go func(transport Transport) {
+			resp, err := transport.RoundTrip(ctx, query)
+			if err == nil {
+				if verr := validateResponse(query, resp); verr != nil {
+					err = verr
+				}
+			}
+			results <- result{resp: resp, err: err}
+		}(transport)
+	}
+
+	var lastErr error
+	for i := 0; i < len(t.transports); i++ {
+		r := <-results
+		if r.err == nil {
+			return r.resp, nil
+		}
+		lastErr = r.err
+	}
+	return nil, lastErr
+}
+
+// buildTransportFromArgs inspects CLI args for --server/--tcp, --dot and
+// --doh flags and returns the Transport they describe, or nil to fall back
+// to the stdlib net.Lookup* path. --doh takes precedence over --dot, which
+// takes precedence over --server, since they're mutually exclusive ways of
+// picking an upstream.
+func buildTransportFromArgs(args []string) Transport {
+	var dotServer, dohURL, serverList string
+	forceTCP := false
+
+	for i, a := range args {
+		switch a {
+		case "--dot":
+			if i+1 < len(args) {
+				dotServer = args[i+1]
+			}
+		case "--doh":
+			if i+1 < len(args) {
+				dohURL = args[i+1]
+			}
+		case "--server":
+			if i+1 < len(args) {
+				serverList = args[i+1]
+			}
+		case "--tcp":
+			forceTCP = true
+		}
+	}
+
+	switch {
+	case dohURL != "":
+		return newDoHTransport(dohURL)
+	case dotServer != "":
+		return &DoTTransport{Server: dotServer}
+	case serverList != "":
+		return newServerTransport(strings.Split(serverList, ","), forceTCP)
+	default:
+		return nil
+	}
+}
+
+// newServerTransport builds the Transport for one or more --server hosts,
+// forcing TCP for every host when forceTCP is set.
+func newServerTransport(servers []string, forceTCP bool) Transport {
+	transports := make([]Transport, 0, len(servers))
+	for _, server := range servers {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+		if forceTCP {
+			transports = append(transports, &TCPTransport{Server: server})
+		} else {
+			transports = append(transports, newDualTransport(server))
+		}
+	}
+	if len(transports) == 1 {
+		return transports[0]
+	}
+	return &multiTransport{transports: transports}
+}
@@ -0,0 +1,107 @@
+package main
+
+import "testing"
+
+func TestSplitHostPort(t *testing.T) {
+	tests := []struct {
+		name        string
+		server      string
+		defaultPort string
+		want        string
+	}{
+		{"bare host gets default port", "8.8.8.8", "53", "8.8.8.8:53"},
+		{"host with explicit port is untouched", "8.8.8.8:53", "53", "8.8.8.8:53"},
+		{"host with a different explicit port is untouched", "8.8.8.8:5353", "53", "8.8.8.8:5353"},
+		{"bare ipv6 host gets bracketed default port", "::1", "53", "[::1]:53"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitHostPort(tt.server, tt.defaultPort); got != tt.want {
+				t.Errorf("splitHostPort(%q, %q) = %q, want %q", tt.server, tt.defaultPort, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDoTTransportHostPort(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   string
+		wantHost string
+		wantAddr string
+	}{
+		{"bare host defaults to 853", "1.1.1.1", "1.1.1.1", "1.1.1.1:853"},
+		{"explicit port is kept", "1.1.1.1:8853", "1.1.1.1", "1.1.1.1:8853"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &DoTTransport{Server: tt.server}
+			host, addr := tr.hostPort()
+			if host != tt.wantHost {
+				t.Errorf("hostPort() host = %q, want %q", host, tt.wantHost)
+			}
+			if addr != tt.wantAddr {
+				t.Errorf("hostPort() addr = %q, want %q", addr, tt.wantAddr)
+			}
+		})
+	}
+}
+
+func TestBuildTransportFromArgsPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string // Go type name of the returned Transport ("*UDPTransport": a dualTransport wrapping UDP), or "" for nil
+	}{
+		{"no flags", nil, ""},
+		{"server only", []string{"--server", "8.8.8.8"}, "*UDPTransport"},
+		{"dot wins over server", []string{"--server", "8.8.8.8", "--dot", "1.1.1.1"}, "*DoTTransport"},
+		{"doh wins over dot and server", []string{"--server", "8.8.8.8", "--dot", "1.1.1.1", "--doh", "https://dns.example/dns-query"}, "*DoHTransport"},
+		{"server with --tcp", []string{"--server", "8.8.8.8", "--tcp"}, "*TCPTransport"},
+		{"multiple servers", []string{"--server", "8.8.8.8,1.1.1.1"}, "*multiTransport"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildTransportFromArgs(tt.args)
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("buildTransportFromArgs(%v) = %T, want nil", tt.args, got)
+				}
+				return
+			}
+			switch tt.want {
+			case "*UDPTransport":
+				if dt, ok := got.(*dualTransport); !ok || dt.udp == nil {
+					t.Errorf("buildTransportFromArgs(%v) = %T, want a dualTransport wrapping UDP", tt.args, got)
+				}
+			case "*DoTTransport":
+				if _, ok := got.(*DoTTransport); !ok {
+					t.Errorf("buildTransportFromArgs(%v) = %T, want *DoTTransport", tt.args, got)
+				}
+			case "*DoHTransport":
+				if _, ok := got.(*DoHTransport); !ok {
+					t.Errorf("buildTransportFromArgs(%v) = %T, want *DoHTransport", tt.args, got)
+				}
+			case "*TCPTransport":
+				if _, ok := got.(*TCPTransport); !ok {
+					t.Errorf("buildTransportFromArgs(%v) = %T, want *TCPTransport", tt.args, got)
+				}
+			case "*multiTransport":
+				if _, ok := got.(*multiTransport); !ok {
+					t.Errorf("buildTransportFromArgs(%v) = %T, want *multiTransport", tt.args, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNewServerTransportSkipsBlankEntries(t *testing.T) {
+	got := newServerTransport([]string{" 8.8.8.8 ", "", "1.1.1.1"}, false)
+	mt, ok := got.(*multiTransport)
+	if !ok {
+		t.Fatalf("newServerTransport(...) = %T, want *multiTransport", got)
+	}
+	if len(mt.transports) != 2 {
+		t.Fatalf("newServerTransport skipped blanks incorrectly: got %d transports, want 2", len(mt.transports))
+	}
+}